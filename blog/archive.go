@@ -0,0 +1,106 @@
+package blog
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// Templates a theme must provide.
+
+var requiredTemplates = []string{"root.tmpl", "home.tmpl", "index.tmpl", "article.tmpl", "page.tmpl", "doc.tmpl"}
+
+// OpenFS: returns the file systems holding the articles and the theme, either
+// the ArticlePath and ThemePath directories or the content and theme
+// directories of the ArchivePath. The archive is read into memory, so no file
+// is left open and it may be replaced while being served.
+
+func openFS(cfg Config) (content, theme fs.FS, err error) {
+	if cfg.ArchivePath == "" {
+		return os.DirFS(cfg.ArticlePath), os.DirFS(cfg.ThemePath), nil
+	}
+
+	b, err := os.ReadFile(cfg.ArchivePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("blog: archive: %w", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("blog: archive %s: %w", cfg.ArchivePath, err)
+	}
+
+	if info, err := fs.Stat(zr, "content"); err != nil || !info.IsDir() {
+		return nil, nil, fmt.Errorf("blog: archive %s lacks a content directory", cfg.ArchivePath)
+	}
+
+	for _, name := range requiredTemplates {
+		if _, err := fs.Stat(zr, path.Join("theme", name)); err != nil {
+			return nil, nil, fmt.Errorf("blog: archive %s lacks theme/%s", cfg.ArchivePath, name)
+		}
+	}
+
+	content, _ = fs.Sub(zr, "content")
+	theme, _ = fs.Sub(zr, "theme")
+
+	return seekableFS{content}, theme, nil
+}
+
+// SeekableFS: wraps a file system whose files cannot seek, such as a zip
+// archive, reading its files into memory so that http.FS can serve them.
+
+type seekableFS struct {
+	fs.FS
+}
+
+func (fsys seekableFS) Open(name string) (fs.File, error) {
+	f, err := fsys.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := f.(io.Seeker); ok {
+		return f, nil
+	}
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return f, err
+	}
+
+	defer f.Close()
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return &memFile{Reader: bytes.NewReader(b), info: info}, nil
+}
+
+// MemFile: a file read into memory.
+
+type memFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *memFile) Close() error { return nil }
+
+// SourceName: returns the name of the article file at p, relative to the
+// content file system, used in load errors and given to the parsers.
+
+func (s *Server) sourceName(p string) string {
+	if s.cfg.ArchivePath != "" {
+		return s.cfg.ArchivePath + ":" + path.Join("content", p)
+	}
+
+	return filepath.Join(s.cfg.ArticlePath, filepath.FromSlash(p))
+}
@@ -0,0 +1,55 @@
+package blog
+
+import (
+	"archive/zip"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenFSReadsArchiveIntoMemory(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "site.zip")
+
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zw := zip.NewWriter(f)
+	for _, file := range append([]string{"content/a.article"}, requiredTemplates...) {
+		if file != "content/a.article" {
+			file = "theme/" + file
+		}
+
+		w, err := zw.Create(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w.Write([]byte(file))
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, _, err := openFS(Config{ArchivePath: name})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Served on from memory once the archive is gone.
+	if err := os.Remove(name); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := fs.ReadFile(content, "a.article")
+	if err != nil || string(b) != "content/a.article" {
+		t.Errorf("a.article = %q, %v, want its contents", b, err)
+	}
+}
@@ -0,0 +1,52 @@
+package blog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Fingerprints: maps theme asset names to content-hashed names and back, so
+// that fingerprinted URLs can be cached indefinitely.
+
+type fingerprints struct {
+	hashed  map[string]string // Key is the asset name.
+	logical map[string]string // Key is the fingerprinted name.
+}
+
+// FingerprintAssets: hashes every file of fsys, naming "app.css" as, for
+// example, "app.3f2a9c1b7d4e.css".
+
+func fingerprintAssets(fsys fs.FS) (*fingerprints, error) {
+	fp := &fingerprints{
+		hashed:  make(map[string]string),
+		logical: make(map[string]string),
+	}
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(b)
+		ext := path.Ext(name)
+		hashed := strings.TrimSuffix(name, ext) + "." + hex.EncodeToString(sum[:6]) + ext
+
+		fp.hashed[name] = hashed
+		fp.logical[hashed] = name
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return fp, nil
+}
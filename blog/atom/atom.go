@@ -6,24 +6,30 @@ import (
 )
 
 type Feed struct {
-	XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
-	Title   string   `xml:"title"`
-	ID      string   `xml:"id"`
-	Link    []Link   `xml:"link"`
-	Updated TimeStr  `xml:"updated"`
-	Author  *Person  `xml:"author"`
-	Entry   []*Entry `xml:"entry"`
+	XMLName   xml.Name   `xml:"http://www.w3.org/2005/Atom feed"`
+	Title     string     `xml:"title"`
+	ID        string     `xml:"id"`
+	Link      []Link     `xml:"link"`
+	Updated   TimeStr    `xml:"updated"`
+	Rights    string     `xml:"rights,omitempty"`
+	Author    *Person    `xml:"author"`
+	Generator *Generator `xml:"generator"`
+	Archive   *struct{}  `xml:"http://purl.org/syndication/history/1.0 archive"`  // RFC 5005 archive document.
+	Complete  *struct{}  `xml:"http://purl.org/syndication/history/1.0 complete"` // RFC 5005 complete feed, holding every entry.
+	Entry     []*Entry   `xml:"entry"`
 }
 
 type Entry struct {
-	Title     string  `xml:"title"`
-	ID        string  `xml:"id"`
-	Link      []Link  `xml:"link"`
-	Published TimeStr `xml:"published"`
-	Updated   TimeStr `xml:"updated"`
-	Author    *Person `xml:"author"`
-	Summary   *Text   `xml:"summary"`
-	Content   *Text   `xml:"articles"`
+	Title       string    `xml:"title"`
+	ID          string    `xml:"id"`
+	Link        []Link    `xml:"link"`
+	Published   TimeStr   `xml:"published"`
+	Updated     TimeStr   `xml:"updated"`
+	Rights      string    `xml:"rights,omitempty"`
+	Author      *Person   `xml:"author"`
+	Contributor []*Person `xml:"contributor"`
+	Summary     *Text     `xml:"summary"`
+	Content     *Text     `xml:"articles"`
 }
 
 type Link struct {
@@ -35,6 +41,12 @@ type Link struct {
 	Length   uint   `xml:"length,attr,omitempty"`
 }
 
+type Generator struct {
+	URI     string `xml:"uri,attr,omitempty"`
+	Version string `xml:"version,attr,omitempty"`
+	Name    string `xml:",chardata"`
+}
+
 type Person struct {
 	Name     string `xml:"name"`
 	URI      string `xml:"uri,omitempty"`
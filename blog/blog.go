@@ -1,23 +1,40 @@
 package blog
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+
+	"encoding/base64"
+
 	"html/template"
 
+	"io"
+
+	"io/fs"
+
 	"net/http"
+	"net/url"
 
 	"bytes"
 
 	"time"
 
+	"unicode/utf8"
+
 	"encoding/json"
 
+	"errors"
+
 	"os"
 
-	"path/filepath"
+	"path"
 	"sort"
 
+	"strconv"
 	"strings"
 
+	"sync"
+
 	"log"
 
 	"regexp"
@@ -27,55 +44,612 @@ import (
 	"encoding/xml"
 
 	"github.com/ryank90/utilities/blog/atom"
+	"github.com/ryank90/utilities/blog/jsonfeed"
+	"github.com/ryank90/utilities/blog/rss"
 	"github.com/ryank90/utilities/present"
 )
 
 var validJSONPFunc = regexp.MustCompile(`(?i)^[a-z_][a-z0-9_.]*$`)
 
-// Config: specifies the server configuration values.
+// Matches TeX math delimited by $$...$$ or $...$ (without inner padding, so
+// that prices such as "$5 and $10" are not mistaken for math).
+
+var mathExpr = regexp.MustCompile(`\$\$[^$]+\$\$|\$[^$\s]([^$]*[^$\s])?\$`)
+
+const defaultMathJaxURL = "https://cdn.jsdelivr.net/npm/mathjax@3/es5/tex-chtml.js"
+
+// ParseFunc: parses an article read from r into a present document. The name
+// is used to resolve files referenced by the article.
+
+type ParseFunc func(r io.Reader, name string) (*present.Doc, error)
+
+// Parsers: keyed by article file extension (including the leading dot).
+
+var parsers = map[string]ParseFunc{
+	".article": parsePresent,
+	".slide":   parsePresent,
+}
+
+// RegisterParser: binds the article file extension, including the leading dot,
+// to the parser used for articles with that extension.
+
+func RegisterParser(ext string, parser ParseFunc) {
+	if !strings.HasPrefix(ext, ".") {
+		panic("bad extension in RegisterParser: " + ext)
+	}
+
+	parsers[ext] = parser
+}
+
+// ParsePresent: parses an article in the present format.
+
+func parsePresent(r io.Reader, name string) (*present.Doc, error) {
+	return present.Parse(r, name, 0)
+}
+
+// Maximum length in runes of a generated meta description.
+
+const metaDescriptionLen = 160
+
+// Update periods of the RSS feed and their TTL in minutes.
+
+var updatePeriods = map[string]int{
+	"hourly": 60,
+	"daily":  24 * 60,
+	"weekly": 7 * 24 * 60,
+}
+
+// Default amount of words in a summary of the "first-n-words" strategy.
+
+const defaultSummaryWords = 50
+
+// Default generator advertised by the feeds.
+
+const (
+	defaultGeneratorName = "github.com/ryank90/utilities/blog"
+	defaultGeneratorURI  = "https://github.com/ryank90/utilities"
+)
+
+// Amount of articles nearest in time used as Related when none share tags.
+
+const relatedFallbackDocs = 4
+
+// Reading speed used for the estimate of the progressData helper.
+
+const wordsPerMinute = 200
+
+func init() {
+	present.RegisterMetadata("rights")
+	present.RegisterMetadata("noindex")
+	present.RegisterMetadata("slug")
+	present.RegisterMetadata("draft")
+	present.RegisterMetadata("description")
+	present.RegisterMetadata("canonical")
+	present.RegisterMetadata("summary")
+	present.RegisterMetadata("kind")
+	present.RegisterMetadata("contributors")
+	present.RegisterMetadata("styles")
+	present.RegisterMetadata("scripts")
+	present.RegisterMetadata("updated")
+	present.RegisterMetadata("next")
+	present.RegisterMetadata("order")
+	present.RegisterMetadata("feed")
+}
+
+// Config: specifies the server configuration values. ArticlePath and ThemePath
+// are required unless an ArchivePath is given; zero HomeArticles, FeedArticles, FeedTitle and IndexPath
+// and nil IgnorePatterns and FeedAliases are filled from DefaultConfig by NewServer. Everything else is
+// optional.
 
 type Config struct {
 	ArticlePath string // Path to the article files for the blog.
 	ThemePath   string // Path to the theme files for the blog.
 
+	// ArchivePath names a zip archive holding the articles in its content
+	// directory and the theme in its theme directory, replacing ArticlePath
+	// and ThemePath. Files referenced by .code, .play and .html lines cannot
+	// be read from an archive.
+	ArchivePath string
+
+	Extensions []string // Article file extensions (defaults to ".article"); others are static.
+
+	IgnorePatterns []string // Glob patterns of article files and directories to skip (defaults to dotfiles).
+
 	BaseURL  string // Absolute base URL (for perm-links - no trailing slashes).
 	BasePath string // Base URL path relative to server root - no trailing slashes.
 	Hostname string // Server hostname used for rendering ATOM feeds.
 
-	HomeArticles int    // Amount of Articles to display on the homepage.
-	FeedArticles int    // Amount of Articles to display on the ATOM and JSON feeds.
-	FeedTitle    string // The title of the ATOM XML feed
+	ArticlePrefix string // Path prefix for articles only, e.g. "/posts" - no trailing slashes.
+
+	SlugifyFilenames bool // Derive article paths from slugified file names (see Slugify).
+
+	// SlugCollision selects how articles sharing a path are told apart: the
+	// oldest keeps it and later ones fail to load ("error") or get the suffix
+	// "-2006-01-02" of their date ("date-suffix") or "-2", "-3"... ("numeric-suffix").
+	// By default the last article found takes the path.
+	SlugCollision string
+
+	// SectionIndexes serves the index article of a directory at the directory's
+	// path, e.g. "guide/index.article" at "/guide", as the section's landing page.
+	// HideSectionIndexes also leaves those out of the listings, feeds and tags.
+	SectionIndexes     bool
+	HideSectionIndexes bool
+
+	// TrailingSlash ends the URLs of articles, their Permalink and that of the
+	// postURL helper, with a slash and redirects requests for articles without
+	// one. Relative links in articles are then rooted as with RewriteRelativeLinks
+	// since the slash adds a directory level. Paths and feed IDs are unaffected.
+	TrailingSlash bool
+
+	// CaseInsensitiveURLs redirects requests for an article's path in other
+	// letter case to the article. Paths left percent-encoded, e.g. by copying
+	// an encoded link, are always redirected.
+	CaseInsensitiveURLs bool
+
+	// DateFromFilename dates articles lacking a date line from the start of
+	// their file name, following a time layout where "slug" stands for the
+	// rest of the name, e.g. "2006-01-02-slug".
+	DateFromFilename string
+
+	IndexPath     string // Path of the full article listing (defaults to "/index").
+	DisableIndex  bool   // Disables the listing, so its path is looked up like any other.
+	IndexArticles int    // Amount of Articles per ?page= of the listing (0 shows all on one page).
+	EnableAllPage bool   // Serves every article on one page at /all from all.tmpl, oldest first.
+
+	// CountViews counts the views of articles in memory for Server.Views, the
+	// popularPosts helper and the /popular page (from popular.tmpl, if any).
+	// A ViewStore, if set, keeps the counts across restarts.
+	CountViews bool
+	ViewStore  ViewStore
+
+	NoindexListings  bool // Asks search engines not to index the listing, tag and changelog pages.
+	NoindexPaginated bool // Asks search engines not to index the listing pages beyond the first.
+
+	TagOrder string // Order of the tags and tagCounts helpers, "alpha" (default) or "count" (most used first).
+
+	// SortOrder is the reading order followed by Newer and Older and their
+	// section counterparts: "date" (the default, newest first) or "order-field"
+	// (ascending by the order metadata, then by date, with articles lacking it
+	// last). Listings and feeds always stay newest first.
+	SortOrder string
+
+	TagMeta map[string]TagMeta // Titles and descriptions of tags for tag.tmpl and the tag feeds, keyed by tag.
+
+	// StaticPrefix, when set, limits the static files of the ArticlePath to
+	// paths under it, e.g. "/static"; other unknown paths are served as
+	// 404 Not Found (using 404.tmpl if present).
+	StaticPrefix string
+
+	Gone []string // Paths of removed articles served as 410 Gone (using 410.tmpl if present).
+
+	AssetFS     fs.FS  // Theme assets, e.g. an embed.FS, served under the AssetPrefix.
+	AssetPrefix string // Path prefix of the theme assets (defaults to "/assets") - no trailing slashes.
+
+	FaviconPath string // Path to the file served at /favicon.ico.
+	WebManifest string // Path to the file served at /site.webmanifest.
+
+	SVGSpritePath string // Path to an SVG icon sprite inlined by the svgSprite helper, read once at startup.
+
+	FeedStylesheet string // Path to an XSLT file served at /feed.xsl and referenced by the ATOM feeds.
+
+	MaxRequestBody int64 // Maximum request body size in bytes accepted by Handler (0 is unlimited).
+
+	CachePages  bool // Caches rendered article pages until the next load (not with a ContentSecurityPolicy).
+	WarmOnStart bool // Renders every article into the page cache in NewServer, see Warm.
+
+	TrustProxyHeaders bool // Handler takes the scheme and host from X-Forwarded-Proto and X-Forwarded-Host.
+	ForceHTTPS        bool // Handler redirects requests not made over HTTPS with 301 Moved Permanently.
+
+	SlowRequestThreshold time.Duration // Handler logs a warning for requests taking longer (0 disables).
+	AccessLog            io.Writer     // Handler writes a combined log format line per request to it.
+
+	Debug bool // Logs diagnostics such as the load timings.
+
+	// ContentSecurityPolicy is sent with every page; each "{nonce}" in it is
+	// replaced by a fresh nonce, available to templates through cspNonce.
+	ContentSecurityPolicy string
+
+	HomeArticles  int    // Amount of Articles to display on the homepage.
+	FeedArticles  int    // Amount of Articles to display on the ATOM and JSON feeds.
+	AtomArticles  int    // Amount of Articles on the ATOM feed (defaults to FeedArticles).
+	JSONArticles  int    // Amount of Articles on the JSON feeds (defaults to FeedArticles).
+	FeedMinWords  int    // Leaves shorter Articles out of the main feeds, not the site or tag feeds (0 keeps all).
+	FeedTitle     string // The title of the ATOM XML feed
+	FeedRights    string // Copyright or license statement for the feeds.
+	FeedNextURL   string // Target of the ATOM feed's rel="next" link (defaults to the IndexPath page).
+	GeneratorName string // Name of the software in the ATOM feed's generator (defaults to this package).
+	GeneratorURI  string // URI of the software in the ATOM feed's generator (defaults to this repository).
+
+	FeedAuthorName  string // Author of the feeds, also standing in for articles without authors.
+	FeedAuthorEmail string // Email address of the FeedAuthorName in the ATOM feeds.
+
+	// The ATOM and legacy JSON feeds are served unless disabled, so their
+	// toggles read as Disable to keep the zero Config serving them; the other
+	// formats are only served once enabled.
+	DisableAtom              bool // Disables the ATOM feed.
+	DisableJSON              bool // Disables the legacy JSON feed at /.json.
+	EnableJSONFeed           bool // Enables the JSON Feeds at /feed.json and /tag/<name>.json.
+	DisableLegacyFeedAliases bool // Serves /feeds/posts/default as an article or static file, not the ATOM feed.
+
+	// FeedAliases are paths redirected to a feed when no article is served
+	// there, e.g. "/rss" to the RSS feed if enabled and others to the first feed
+	// of the feedLinks helper (defaults to "/feed", "/rss" and "/atom").
+	FeedAliases []string
+	EnableRSS   bool // Enables the RSS 2.0 feed at /feed.rss.
+
+	FeedUpdatePeriod string // Polling hint of the RSS feed, "hourly", "daily" (default) or "weekly".
+
+	SiteDescription string // Description of the site used for the homepage meta description.
+
+	// AnnouncementHTML is a site-wide notice, e.g. of maintenance, returned by
+	// the announcement helper until AnnouncementExpires (never if zero). Pages
+	// are not cached while it shows. Server.SetAnnouncement replaces both.
+	AnnouncementHTML    template.HTML
+	AnnouncementExpires time.Time
+
+	Language      string // BCP 47 language tag of the site, see lang (defaults to "en").
+	TextDirection string // Text direction of the site, "ltr" (default) or "rtl", see dir.
+
+	StaleAfter time.Duration // Age after which articles are considered stale, see isStale (0 never).
+
+	DateFormat     string // Layout of the fmtDate helper (defaults to "2 January 2006").
+	DateTimeFormat string // Layout of the fmtDateTime helper (defaults to "2 January 2006 15:04").
+	TimeZone       string // IANA name of the zone dates are shown in, e.g. "Europe/London" (defaults to UTC).
+
+	FeedSummaryType string // ATOM summary type, "html" (default) or "text".
+	ReadMoreText    string // Text of the link continuing a summary (defaults to "Read more").
+
+	// SummaryStrategy selects how article summaries are derived: "first-paragraph"
+	// (the default), "first-n-words" (the first SummaryWords words) or "explicit"
+	// (only the summary metadata). A summary metadata line always takes precedence.
+	SummaryStrategy string
+	SummaryWords    int // Amount of words in a "first-n-words" summary (defaults to 50).
+
+	SummaryPlainText bool // Strips the styling from summaries in the feeds; excerpts keep it.
+
+	RelatedFallbackByDate bool          // Fill Related with the nearest articles in time when none share tags.
+	RelatedMaxAge         time.Duration // Exclude Related articles older than this before the article (0 is unlimited).
+
+	RenderTables bool // Renders paragraphs written as pipe tables as HTML tables.
+
+	MarkLede  bool   // Adds the LedeClass to the first paragraph of articles starting with one.
+	LedeClass string // Class of the first paragraph (defaults to "lede").
+
+	// IncludesPath holds the HTML snippets spliced into articles by
+	// ".include name" lines; snippets may include other snippets.
+	IncludesPath string
+
+	ShowNotes bool // Collects presenter notes for the notes helper, e.g. for preview builds.
+
+	CommentsEnabled   bool   // Enables the comments template helper.
+	CommentsScriptURL string // Script URL of the hosted comment widget.
+	CommentsSiteID    string // Site identifier at the comment provider.
+
+	// EditURLTemplate is the URL returned by the editURL helper, with "{path}" standing for the
+	// article file relative to the ArticlePath, e.g.
+	// "https://github.com/me/blog/edit/main/content/{path}".
+	EditURLTemplate string
+
+	MathJax    bool   // Enables client-side rendering of $...$ and $$...$$ math.
+	MathJaxURL string // MathJax script URL (defaults to the jsDelivr bundle).
+
+	// OnReload is called after a successful load with the paths of the
+	// articles that were added, updated or removed.
+	OnReload func(changed []string)
+
+	// PingOnReload lists URLs, e.g. search engine sitemap ping endpoints,
+	// requested in the background after a reload changed articles. Each
+	// "{sitemap}" in them is replaced by the query-escaped SitemapURL.
+	PingOnReload   []string
+	SitemapURL     string // URL of the sitemap (defaults to the one served at /sitemap.xml).
+	DisableSitemap bool   // Disables the sitemap at /sitemap.xml, e.g. to serve one from the StaticPath.
+
+	// AssetRewrite, when set, rewrites the src of images and the href of links
+	// to assets in rendered articles, e.g. to upgrade to HTTPS or use a CDN.
+	AssetRewrite func(src string) string
+
+	// RewriteRelativeLinks roots relative image sources and link targets in
+	// articles at the article file's directory under the BasePath, so they
+	// resolve wherever the article is served.
+	RewriteRelativeLinks bool
+
+	// OnError is called when rendering a page fails, before anything is
+	// written. When nil the error is logged and a 500 is served.
+	OnError func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// DefaultConfig: returns a configuration holding the default values.
+
+func DefaultConfig() Config {
+	return Config{
+		IndexPath:      "/index",
+		IgnorePatterns: []string{".*"},
+		FeedAliases:    []string{"/feed", "/rss", "/atom"},
+		HomeArticles:   10,
+		FeedArticles:   20,
+		FeedTitle:      "Blog",
+	}
+}
+
+// SetDefaults: fills the unset fields that have defaults from DefaultConfig.
+
+func (cfg *Config) setDefaults() {
+	def := DefaultConfig()
+
+	if cfg.HomeArticles == 0 {
+		cfg.HomeArticles = def.HomeArticles
+	}
+
+	if cfg.FeedArticles == 0 {
+		cfg.FeedArticles = def.FeedArticles
+	}
+
+	if cfg.FeedTitle == "" {
+		cfg.FeedTitle = def.FeedTitle
+	}
+
+	if cfg.IndexPath == "" {
+		cfg.IndexPath = def.IndexPath
+	}
+
+	if cfg.IgnorePatterns == nil {
+		cfg.IgnorePatterns = def.IgnorePatterns
+	}
+
+	if cfg.FeedAliases == nil {
+		cfg.FeedAliases = def.FeedAliases
+	}
+}
+
+// Validate: checks the configuration for missing or malformed values, returning
+// an error naming the offending field.
+
+func (cfg Config) Validate() error {
+	if cfg.ArticlePath == "" && cfg.ArchivePath == "" {
+		return errors.New("blog: Config.ArticlePath is required")
+	}
+
+	if cfg.ThemePath == "" && cfg.ArchivePath == "" {
+		return errors.New("blog: Config.ThemePath is required")
+	}
+
+	counts := []struct {
+		name string
+		n    int
+	}{
+		{"HomeArticles", cfg.HomeArticles},
+		{"FeedArticles", cfg.FeedArticles},
+		{"AtomArticles", cfg.AtomArticles},
+		{"JSONArticles", cfg.JSONArticles},
+		{"FeedMinWords", cfg.FeedMinWords},
+		{"SummaryWords", cfg.SummaryWords},
+	}
+
+	for _, c := range counts {
+		if c.n < 0 {
+			return fmt.Errorf("blog: Config.%s must not be negative, got %d", c.name, c.n)
+		}
+	}
+
+	paths := []struct {
+		name, value string
+	}{
+		{"BaseURL", cfg.BaseURL},
+		{"BasePath", cfg.BasePath},
+		{"ArticlePrefix", cfg.ArticlePrefix},
+		{"AssetPrefix", cfg.AssetPrefix},
+		{"StaticPrefix", cfg.StaticPrefix},
+	}
+
+	for _, p := range paths {
+		if strings.HasSuffix(p.value, "/") {
+			return fmt.Errorf("blog: Config.%s must not have a trailing slash, got %q", p.name, p.value)
+		}
+	}
+
+	if _, ok := updatePeriods[cfg.FeedUpdatePeriod]; !ok && cfg.FeedUpdatePeriod != "" {
+		return fmt.Errorf("blog: Config.FeedUpdatePeriod is unknown, got %q", cfg.FeedUpdatePeriod)
+	}
+
+	for _, alias := range cfg.FeedAliases {
+		if !strings.HasPrefix(alias, "/") {
+			return fmt.Errorf("blog: Config.FeedAliases must hold rooted paths, got %q", alias)
+		}
+	}
+
+	for _, pattern := range cfg.IgnorePatterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("blog: Config.IgnorePatterns has a malformed pattern %q", pattern)
+		}
+	}
+
+	if _, err := time.LoadLocation(cfg.TimeZone); err != nil {
+		return fmt.Errorf("blog: Config.TimeZone is unknown, got %q", cfg.TimeZone)
+	}
+
+	if len(cfg.PingOnReload) > 0 && cfg.DisableSitemap && cfg.SitemapURL == "" {
+		return errors.New("blog: Config.SitemapURL is required to ping with the sitemap disabled")
+	}
+
+	switch cfg.TagOrder {
+	case "", "alpha", "count":
+	default:
+		return fmt.Errorf("blog: Config.TagOrder must be \"alpha\" or \"count\", got %q", cfg.TagOrder)
+	}
+
+	switch cfg.SortOrder {
+	case "", "date", "order-field":
+	default:
+		return fmt.Errorf("blog: Config.SortOrder must be \"date\" or \"order-field\", got %q", cfg.SortOrder)
+	}
+
+	switch cfg.TextDirection {
+	case "", "ltr", "rtl":
+	default:
+		return fmt.Errorf("blog: Config.TextDirection must be \"ltr\" or \"rtl\", got %q", cfg.TextDirection)
+	}
+
+	switch cfg.SlugCollision {
+	case "", "error", "date-suffix", "numeric-suffix":
+	default:
+		return fmt.Errorf("blog: Config.SlugCollision is unknown, got %q", cfg.SlugCollision)
+	}
+
+	switch cfg.SummaryStrategy {
+	case "", "first-paragraph", "first-n-words", "explicit":
+	default:
+		return fmt.Errorf("blog: Config.SummaryStrategy is unknown, got %q", cfg.SummaryStrategy)
+	}
+
+	switch cfg.FeedSummaryType {
+	case "", "html", "text":
+	default:
+		return fmt.Errorf("blog: Config.FeedSummaryType must be \"html\" or \"text\", got %q", cfg.FeedSummaryType)
+	}
+
+	return nil
+}
+
+// TagMeta: specifies how a tag is presented on its page and feeds.
+
+type TagMeta struct {
+	Title       string // Display title (defaults to the tag).
+	Description string // Introduction and meta description of the tag page.
 }
 
 // Doc: specifies an article full of articles.
 
 type Doc struct {
 	*present.Doc
-	Permalink string        // Canonical URL for this document.
-	Path      string        // Path relative to server root (including base).
-	Intro     string        // Introduction line for the document.
-	Image     string        // Image for the document.
-	Category  string        // Category for the document.
-	HTML      template.HTML // Rendered articles.
-
-	Related      []*Doc // Related articles.
-	Newer, Older *Doc   // Supporting newer and older articles.
+	Permalink    string        // Permanent URL for this document.
+	Canonical    string        // Canonical URL when the document is syndicated from elsewhere.
+	Path         string        // Path relative to server root (including base).
+	Source       string        // Article file relative to the ArticlePath, slash-separated.
+	SectionIndex bool          // Whether the article is its directory's landing page (see Config.SectionIndexes).
+	Intro        string        // Introduction line for the document.
+	Image        string        // Image for the document.
+	Category     string        // Category for the document.
+	Description  string        // Description for search results from the article metadata.
+	Rights       string        // Copyright or license statement overriding the feed's.
+	Summary      template.HTML // Summary for listings and feeds (see Config.SummaryStrategy).
+	More         bool          // Whether the HTML marks where the article continues after the Summary.
+	HTML         template.HTML // Rendered articles.
+	Math         bool          // Whether the rendered article contains math.
+	NoIndex      bool          // Whether search engines are asked not to index the article.
+	NoFeed       bool          // Whether the article is left out of the feeds ("feed: false").
+	Notes        []string      // Presenter notes, only collected when Config.ShowNotes is set.
+	Stale        bool          // Whether the article was older than Config.StaleAfter when loaded.
+	Updated      time.Time     // Time of the last notable edit from the metadata, if any.
+	Kind         string        // Kind of article from its metadata, e.g. "snippet".
+	slugPath     string        // Path relative to the ArticlePrefix before resolving SlugCollision.
+	WordCount    int           // Amount of words of the rendered article.
+	Chars        int           // Amount of characters of the text of the rendered article.
+	Order        int           // Position from the order metadata (see Config.SortOrder).
+	Raw          string        // Plain text of the code of a snippet article.
+	Contributors []string      // Contributors such as editors and reviewers, from the metadata.
+	Styles       []string      // Stylesheet URLs needed by the article, from the metadata.
+	Scripts      []string      // Script URLs needed by the article, from the metadata.
+
+	Related                    []*Doc // Related articles.
+	RecommendedNext            *Doc   // Article to read next from the metadata, preferred over Related.
+	Newer, Older               *Doc   // Supporting newer and older articles.
+	SectionNewer, SectionOlder *Doc   // Newer and older articles in the same directory.
 }
 
 // Server: implements a http.handler that serves articles.
 
 type Server struct {
-	cfg      Config          // Configuration.
-	docs     []*Doc          // Articles.
-	tags     []string        // Tags.
-	docPaths map[string]*Doc // Key is path without the BasePath.
-	docTags  map[string][]*Doc
+	cfg      Config       // Configuration.
+	mu       sync.RWMutex // Guards the articles and pre-rendered feeds.
+	loaded                // Articles and pre-rendered feeds.
+	report   LoadReport   // Outcome of the last load.
+	timings  LoadTimings  // Durations of the phases of the last load.
 	template struct {
-		home, index, article, page, doc *template.Template
+		home, index, article, page, doc              *template.Template
+		gone, notFound, changelog, tag, all, popular *template.Template // Optional.
 	}
-	atomFeed []byte // Pre-rendered ATOM feed.
-	jsonFeed []byte // Pre-rendered JSON feed.
-	content  http.Handler
+	gone              map[string]bool // Key is path without the BasePath.
+	content           http.Handler
+	svgSprite         template.HTML      // Contents of the SVGSpritePath.
+	contentFS         fs.FS              // Article files, from the ArticlePath or ArchivePath.
+	themeFS           fs.FS              // Theme files, from the ThemePath or ArchivePath.
+	assets            http.Handler       // Serves the AssetFS; nil without one.
+	assetFingerprints *fingerprints      // Content-hashed names of the AssetFS files.
+	logMu             sync.Mutex         // Serialises writes to the AccessLog.
+	pages             map[string][]byte  // Cached article pages, keyed as docPaths.
+	allPageHTML       []byte             // Cached /all page.
+	ogImages          map[string][]byte  // Rendered OpenGraph images, keyed as docPaths.
+	pagesMu           sync.Mutex         // Guards pages, allPageHTML and ogImages, which are filled while serving.
+	subs              []chan ReloadEvent // Subscribers of ReloadEvents.
+	subsMu            sync.Mutex         // Guards subs.
+	location          *time.Location     // Zone of the TimeZone.
+	views             map[string]int     // View counts, keyed as docPaths.
+	viewsDirty        map[string]int     // Counts not yet saved to the ViewStore.
+	viewsSaving       bool               // Whether saveViews is running.
+	viewsMu           sync.Mutex         // Guards views, viewsDirty and viewsSaving, which are filled while serving.
+
+	announcementHTML    template.HTML // Current AnnouncementHTML (see SetAnnouncement).
+	announcementExpires time.Time     // Current AnnouncementExpires.
+	announcementMu      sync.Mutex    // Guards announcementHTML and announcementExpires.
+}
+
+// Loaded: holds the state of the Server built by a load, which a failed load
+// puts back as a whole.
+
+type loaded struct {
+	docs         []*Doc          // Articles.
+	tags         []string        // Tags.
+	docPaths     map[string]*Doc // Key is path without the BasePath.
+	docFolded    map[string]*Doc // Key is the lower-cased docPaths key, with CaseInsensitiveURLs.
+	docTags      map[string][]*Doc
+	docAuthors   map[string][]*Doc    // Key is the slugified author name.
+	sources      map[string]docSource // Key is the article file path.
+	atomFeed     []byte               // Pre-rendered ATOM feed.
+	atomArchive  [][]byte             // Pre-rendered ATOM archive documents, oldest first.
+	authorFeeds  map[string][]byte    // Pre-rendered per-author ATOM feeds, keyed as docAuthors.
+	jsonFeed     []byte               // Pre-rendered JSON feed.
+	feedJSON     []byte               // Pre-rendered JSON Feed (jsonfeed.org).
+	tagFeedsJSON map[string][]byte    // Pre-rendered per-tag JSON Feeds, keyed by tag.
+	rssFeed      []byte               // Pre-rendered RSS feed.
+	sitemap      []byte               // Pre-rendered sitemap.
+}
+
+// LoadReport: summarises the outcome of the last load of the articles.
+
+type LoadReport struct {
+	Published int         // Articles being served.
+	Drafts    int         // Articles skipped for being marked "draft: true".
+	Future    int         // Published articles dated after the load.
+	Errors    []LoadError // Articles that failed to load.
+	Warnings  []LoadError // Articles that loaded with problems, e.g. a missing "next" article.
+}
+
+// LoadTimings: records how long each phase of loading the Server took.
+
+type LoadTimings struct {
+	Templates time.Duration // Parsing the theme templates (NewServer only).
+	Content   time.Duration // Parsing and rendering the articles.
+	Feeds     time.Duration // Rendering the feeds.
+}
+
+// LoadError: records an article file that failed to load.
+
+type LoadError struct {
+	File string
+	Err  error
+}
+
+func (e LoadError) Error() string {
+	return e.File + ": " + e.Err.Error()
+}
+
+// DocSource: records the content hash of a loaded article file so unchanged
+// files can be reused on reload.
+
+type docSource struct {
+	hash     [sha256.Size]byte
+	doc      *Doc
+	warnings []LoadError // Reported again whenever the file is reused.
 }
 
 // JsonItem: specifies a JSON item.
@@ -89,94 +663,676 @@ type jsonItem struct {
 	Author  string
 }
 
-// RootData: encapsulates data destined for the root theme.
+// RelatedItem: specifies a related article served by /related.json.
 
-type rootData struct {
-	Doc      *Doc
-	BasePath string
-	Data     interface{}
+type relatedItem struct {
+	Title string
+	Link  string
+	Time  time.Time
 }
 
-// NewServer constructs a new server using the specified configuration.
+// RelatedItems: returns the related articles of the Doc (Article) for /related.json.
 
-func NewServer(cfg Config) (*Server, error) {
-	root := filepath.Join(cfg.ThemePath, "root.tmpl")
-	parse := func(name string) (*template.Template, error) {
-		t := template.New("").Funcs(funcMap)
-		return t.ParseFiles(root, filepath.Join(cfg.ThemePath, name))
+func relatedItems(doc *Doc) []relatedItem {
+	items := make([]relatedItem, 0, len(doc.Related))
+
+	for _, r := range doc.Related {
+		items = append(items, relatedItem{
+			Title: r.Title,
+			Link:  r.Permalink,
+			Time:  r.Time,
+		})
 	}
 
-	s := &Server{cfg: cfg}
+	return items
+}
 
-	// Parse templates.
-	var err error
-	s.template.home, err = parse("home.tmpl")
-	if err != nil {
-		return nil, err
-	}
-	s.template.index, err = parse("index.tmpl")
-	if err != nil {
-		return nil, err
+// TagCount: a tag and the amount of articles carrying it.
+
+type tagCount struct {
+	Tag   string
+	Count int
+}
+
+// TagCounts: returns the tags with their amount of articles, in the TagOrder.
+
+func (s *Server) tagCounts() []tagCount {
+	counts := make([]tagCount, len(s.tags))
+
+	for i, t := range s.tags {
+		counts[i] = tagCount{Tag: t, Count: len(s.docTags[t])}
 	}
-	s.template.article, err = parse("article.tmpl")
-	if err != nil {
-		return nil, err
+
+	return counts
+}
+
+// TagPage: the data of a tag page, or of the page of several tags at
+// /tags/a+b, rendered through tag.tmpl. Posts may be empty for several tags.
+
+type tagPage struct {
+	Tag         string
+	Title       string
+	Description string
+	Posts       []*Doc
+}
+
+// TagPage: returns the page of the tag with its metadata and articles.
+
+func (s *Server) tagPage(tag string) tagPage {
+	meta := s.tagMeta(tag)
+
+	return tagPage{Tag: tag, Title: meta.Title, Description: meta.Description, Posts: s.docTags[tag]}
+}
+
+// TagsPage: returns the page of the articles carrying all of the tags, titled
+// after them together. The page is empty rather than missing when no article
+// carries them all.
+
+func (s *Server) tagsPage(tags []string) tagPage {
+	titles := make([]string, len(tags))
+
+	for i, tag := range tags {
+		titles[i] = s.tagMeta(tag).Title
 	}
-	s.template.page, err = parse("page.tmpl")
-	if err != nil {
-		return nil, err
+
+	var posts []*Doc
+
+	for _, doc := range s.docTags[tags[0]] {
+		all := true
+
+		for _, tag := range tags[1:] {
+			all = all && hasTag(doc, tag)
+		}
+
+		if all {
+			posts = append(posts, doc)
+		}
 	}
-	p := present.Template().Funcs(funcMap)
-	s.template.doc, err = p.ParseFiles(filepath.Join(cfg.ThemePath, "doc.tmpl"))
-	if err != nil {
-		return nil, err
+
+	return tagPage{Tag: strings.Join(tags, "+"), Title: strings.Join(titles, " + "), Posts: posts}
+}
+
+// HasTag: reports whether the Doc (Article) carries the tag.
+
+func hasTag(doc *Doc, tag string) bool {
+	for _, t := range doc.Tags {
+		if t == tag {
+			return true
+		}
 	}
 
-	// Load articles.
-	err = s.loadDocs(filepath.Clean(cfg.ArticlePath))
+	return false
+}
 
-	if err != nil {
-		return nil, err
+// TagMeta: returns the configured TagMeta of the tag, its title defaulting to the tag.
+
+func (s *Server) tagMeta(tag string) TagMeta {
+	meta := s.cfg.TagMeta[tag]
+	if meta.Title == "" {
+		meta.Title = tag
 	}
 
-	err = s.renderAtomFeed()
-	if err != nil {
-		return nil, err
+	return meta
+}
+
+// Change: an entry of the changelog, an article being published or updated.
+
+type change struct {
+	Doc     *Doc
+	Time    time.Time // When the article was published or last updated.
+	Updated bool      // Whether the change is an update rather than a publish.
+}
+
+// Changelog: returns the most recent changes to the articles, the amount being
+// the FeedArticles, newest first.
+
+func (s *Server) changelog() []change {
+	changes := make([]change, 0, len(s.docs))
+
+	for _, doc := range s.docs {
+		changes = append(changes, change{Doc: doc, Time: lastChanged(doc), Updated: doc.Updated.After(doc.Time)})
 	}
 
-	err = s.renderJSONFeed()
-	if err != nil {
-		return nil, err
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Time.After(changes[j].Time)
+	})
+
+	if len(changes) > s.cfg.FeedArticles {
+		changes = changes[:s.cfg.FeedArticles]
 	}
 
-	// Set up articles file server.
-	s.content = http.StripPrefix(s.cfg.BasePath, http.FileServer(http.Dir(cfg.ArticlePath)))
+	return changes
+}
 
-	return s, nil
+// AllEntry: an article of the /all page, with the id of its anchor.
+
+type allEntry struct {
+	*Doc
+	Anchor string // Unique id for linking to the article within the page.
 }
 
-// ServeHTTP servers the templates as well as the ATOM and JSON feeds.
+// AllPage: returns every article for the /all page, oldest first.
 
-func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	var (
-		d = rootData{BasePath: s.cfg.BasePath}
-		t *template.Template
-	)
-	switch p := strings.TrimPrefix(r.URL.Path, s.cfg.BasePath); p {
-	case "/":
-		d.Data = s.docs
+func (s *Server) allPage() []allEntry {
+	entries := make([]allEntry, 0, len(s.docs))
+
+	for i := len(s.docs) - 1; i >= 0; i-- {
+		doc := s.docs[i]
+		p := strings.TrimPrefix(doc.Path, s.cfg.BasePath+s.cfg.ArticlePrefix)
+
+		entries = append(entries, allEntry{Doc: doc, Anchor: Slugify(p)})
+	}
+
+	return entries
+}
+
+// LastChanged: returns when the Doc (Article) was published or, if later, last updated.
+
+func lastChanged(doc *Doc) time.Time {
+	if doc.Updated.After(doc.Time) {
+		return doc.Updated
+	}
+
+	return doc.Time
+}
+
+// DateFromFilename: parses the date at the start of the file name following the
+// pattern, a time layout in which "slug" stands for the rest of the name.
+
+func dateFromFilename(name, pattern string) (time.Time, error) {
+	layout, _, _ := strings.Cut(pattern, "slug")
+	if len(name) >= len(layout) {
+		if t, err := time.Parse(layout, name[:len(layout)]); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("blog: file name %q does not match %q", name, pattern)
+}
+
+// Layouts accepted by the updated metadata, as for the present date line.
+
+var updatedLayouts = []string{
+	"15:04 2 Jan 2006",
+	"2 Jan 2006",
+	"2006-01-02",
+	time.RFC3339,
+}
+
+// ParseUpdated: parses the value of the updated metadata.
+
+func parseUpdated(v string) (time.Time, error) {
+	for _, layout := range updatedLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("blog: malformed updated time %q", v)
+}
+
+// YearPosts: groups the articles published in a year.
+
+type yearPosts struct {
+	Year  int
+	Posts []*Doc
+}
+
+// FeedLink: describes an enabled feed for autodiscovery.
+
+type feedLink struct {
+	Type  string
+	Title string
+	Href  string
+}
+
+// Comments: describes the comment widget for an article.
+
+type comments struct {
+	ScriptURL string
+	SiteID    string
+	ThreadKey string // Identifies the article's thread: its Path.
+	URL       string // Permalink of the article.
+}
+
+// RootData: encapsulates data destined for the root theme.
+
+type rootData struct {
+	Doc      *Doc
+	BasePath string
+	Data     interface{}
+	Nonce    string // Content security policy nonce for inline scripts.
+	Robots   string // Robots directives of the page, also sent as X-Robots-Tag; empty when indexable.
+
+	Page, Pages int // Current page (from 1) and page count of paginated listings.
+}
+
+// PageLink: describes an entry of a pagination control; Gap entries stand for
+// the pages left out between two links.
+
+type pageLink struct {
+	Number  int
+	URL     string
+	Current bool
+	Gap     bool
+}
+
+// NewServer constructs a new server using the specified configuration.
+
+func NewServer(cfg Config) (*Server, error) {
+	cfg.setDefaults()
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := &Server{cfg: cfg, announcementHTML: cfg.AnnouncementHTML, announcementExpires: cfg.AnnouncementExpires}
+	s.location, _ = time.LoadLocation(cfg.TimeZone) // Checked by Validate.
+	funcs := s.funcMap()
+
+	for _, ext := range s.extensions() {
+		if parsers[ext] == nil {
+			return nil, fmt.Errorf("blog: no parser registered for extension %q", ext)
+		}
+	}
+
+	// Parse templates.
+	start := time.Now()
+
+	var err error
+	s.contentFS, s.themeFS, err = openFS(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	parse := func(name string) (*template.Template, error) {
+		t := template.New("").Funcs(funcs)
+		return t.ParseFS(s.themeFS, "root.tmpl", name)
+	}
+	parseOptional := func(name string) (*template.Template, error) {
+		if _, err := fs.Stat(s.themeFS, name); errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return parse(name)
+	}
+
+	s.template.home, err = parse("home.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	s.template.index, err = parse("index.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	s.template.article, err = parse("article.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	s.template.page, err = parse("page.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	s.template.gone, err = parseOptional("410.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	s.template.notFound, err = parseOptional("404.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	s.template.changelog, err = parseOptional("changelog.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	s.template.tag, err = parseOptional("tag.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	if cfg.CountViews {
+		s.template.popular, err = parseOptional("popular.tmpl")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.EnableAllPage {
+		s.template.all, err = parse("all.tmpl")
+		if err != nil {
+			return nil, err
+		}
+	}
+	p := present.Template().Funcs(funcs)
+	s.template.doc, err = p.ParseFS(s.themeFS, "doc.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.SVGSpritePath != "" {
+		b, err := os.ReadFile(cfg.SVGSpritePath)
+		if err != nil {
+			return nil, err
+		}
+		s.svgSprite = template.HTML(b)
+	}
+
+	if cfg.CountViews {
+		if err := s.loadViews(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.gone = make(map[string]bool)
+
+	for _, p := range cfg.Gone {
+		s.gone[p] = true
+	}
+
+	s.timings.Templates = time.Since(start)
+
+	// Load articles.
+	changed, err := s.load()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Set up articles file server. Files are also served under the ArticlePrefix
+	// so that assets referenced relative to an article resolve.
+	files := http.FileServer(http.FS(s.contentFS))
+	s.content = http.StripPrefix(s.cfg.BasePath, files)
+
+	if s.cfg.ArticlePrefix != "" {
+		articles := http.StripPrefix(s.cfg.BasePath+s.cfg.ArticlePrefix, files)
+		content := s.content
+		s.content = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, s.cfg.BasePath+s.cfg.ArticlePrefix+"/") {
+				articles.ServeHTTP(w, r)
+				return
+			}
+			content.ServeHTTP(w, r)
+		})
+	}
+
+	if cfg.AssetFS != nil {
+		s.assets = http.StripPrefix(s.cfg.BasePath+s.assetPrefix(), http.FileServer(http.FS(cfg.AssetFS)))
+
+		s.assetFingerprints, err = fingerprintAssets(cfg.AssetFS)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.WarmOnStart {
+		err = s.Warm()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if s.cfg.OnReload != nil {
+		s.cfg.OnReload(changed)
+	}
+
+	return s, nil
+}
+
+// Reload: re-reads the articles from the ArticlePath and re-renders the feeds.
+// Only files whose content changed since the last load are parsed again.
+
+func (s *Server) Reload() error {
+	s.mu.Lock()
+	changed, err := s.load()
+	s.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	if s.cfg.OnReload != nil {
+		s.cfg.OnReload(append([]string(nil), changed...))
+	}
+
+	s.publishReload(changed)
+
+	if len(s.cfg.PingOnReload) > 0 && len(changed) > 0 {
+		go s.ping()
+	}
+
+	return nil
+}
+
+// Extensions: returns the configured article file extensions.
+
+func (s *Server) extensions() []string {
+	if len(s.cfg.Extensions) == 0 {
+		return []string{".article"}
+	}
+
+	return s.cfg.Extensions
+}
+
+// Load: reads the articles and renders the feeds, returning the paths of the
+// articles that changed. The previous articles and feeds are put back when any
+// step fails, so a half-built load is never served. The caller must hold the
+// write lock once the Server is being served.
+
+func (s *Server) load() ([]string, error) {
+	prev := s.loaded
+
+	changed, err := s.build()
+	if err != nil {
+		s.loaded = prev
+		return nil, err
+	}
+
+	s.pagesMu.Lock()
+	s.pages, s.allPageHTML, s.ogImages = nil, nil, nil
+	s.pagesMu.Unlock()
+
+	if s.cfg.Debug {
+		log.Printf("blog: loaded %d articles: templates %v, content %v, feeds %v",
+			len(s.docs), s.timings.Templates, s.timings.Content, s.timings.Feeds)
+	}
+
+	return changed, nil
+}
+
+// Build: reads the articles and renders the feeds over the loaded state of the
+// Server, returning the paths of the articles that changed.
+
+func (s *Server) build() ([]string, error) {
+	start := time.Now()
+
+	changed, err := s.loadDocs()
+	if err != nil {
+		return nil, err
+	}
+
+	s.timings.Content = time.Since(start)
+	start = time.Now()
+
+	err = s.renderAtomFeed()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.renderAuthorFeeds()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.renderJSONFeed()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.renderFeedJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.renderTagFeedsJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.renderRSSFeed()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.renderSitemap()
+	if err != nil {
+		return nil, err
+	}
+
+	s.timings.Feeds = time.Since(start)
+
+	return changed, nil
+}
+
+// ServeHTTP servers the templates as well as the ATOM and JSON feeds.
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var (
+		d      = rootData{BasePath: s.cfg.BasePath}
+		t      *template.Template
+		status = http.StatusOK
+	)
+	p := strings.TrimPrefix(r.URL.Path, s.cfg.BasePath)
+
+	switch {
+	case p == "/":
+		d.Data = s.docs
 		if len(s.docs) > s.cfg.HomeArticles {
 			d.Data = s.docs[:s.cfg.HomeArticles]
 		}
 		t = s.template.home
-	case "/index":
-		d.Data = s.docs
+	case !s.cfg.DisableIndex && p == s.cfg.IndexPath:
+		docs, page, pages, ok := paged(s.docs, s.cfg.IndexArticles, r.FormValue("page"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		d.Data, d.Page, d.Pages = docs, page, pages
 		t = s.template.index
-	case "/feed.atom", "/feeds/posts/default":
+		if s.cfg.NoindexListings || s.cfg.NoindexPaginated && page > 1 {
+			d.Robots = "noindex, follow"
+		}
+	case strings.HasPrefix(p, "/tag/") && s.template.tag != nil && s.docTags[strings.TrimPrefix(p, "/tag/")] != nil:
+		d.Data = s.tagPage(strings.TrimPrefix(p, "/tag/"))
+		t = s.template.tag
+		if s.cfg.NoindexListings {
+			d.Robots = "noindex, follow"
+		}
+	case strings.HasPrefix(p, "/tags/") && s.template.tag != nil:
+		tags := strings.Split(strings.TrimPrefix(p, "/tags/"), "+")
+		for _, tag := range tags {
+			if tag == "" {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		d.Data = s.tagsPage(tags)
+		t = s.template.tag
+		if s.cfg.NoindexListings {
+			d.Robots = "noindex, follow"
+		}
+	case p == "/changelog" && s.template.changelog != nil:
+		d.Data = s.changelog()
+		t = s.template.changelog
+		if s.cfg.NoindexListings {
+			d.Robots = "noindex, follow"
+		}
+	case p == "/popular" && s.template.popular != nil:
+		d.Data = s.popularPosts(s.cfg.HomeArticles)
+		t = s.template.popular
+		if s.cfg.NoindexListings {
+			d.Robots = "noindex, follow"
+		}
+	case p == "/all" && s.template.all != nil:
+		if s.cfg.NoindexListings {
+			d.Robots = "noindex, follow"
+		}
+		// The page holds every article, so render it once per load.
+		if page := s.cachedAllPage(); page != nil {
+			if d.Robots != "" {
+				w.Header().Set("X-Robots-Tag", d.Robots)
+			}
+			w.Header().Set("Content-type", "text/html; charset=utf-8")
+			w.Header().Set("Content-Length", strconv.Itoa(len(page)))
+			w.Write(page)
+			return
+		}
+		d.Data = s.allPage()
+		t = s.template.all
+	case p == "/feed.atom" || p == "/feeds/posts/default" && !s.cfg.DisableLegacyFeedAliases:
+		if s.cfg.DisableAtom {
+			http.NotFound(w, r)
+			return
+		}
+		data := s.atomFeed
+		if page := r.FormValue("page"); page != "" {
+			n, err := strconv.Atoi(page)
+			if err != nil || n < 1 || n > len(s.atomArchive) {
+				http.NotFound(w, r)
+				return
+			}
+			data = s.atomArchive[n-1]
+		}
 		w.Header().Set("Content-type", "application/atom+xml; charset=utf-8")
-		w.Write(s.atomFeed)
+		w.Write(data)
 		return
-	case "/.json":
+	case s.assets != nil && strings.HasPrefix(p, s.assetPrefix()+"/"):
+		// Fingerprinted names never change content, so serve them as immutable.
+		if name, ok := s.assetFingerprints.logical[strings.TrimPrefix(p, s.assetPrefix()+"/")]; ok {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+			r = r.Clone(r.Context())
+			r.URL.Path = s.asset(name)
+			r.URL.RawPath = ""
+		}
+		s.assets.ServeHTTP(w, r)
+		return
+	case p == "/favicon.ico" && s.cfg.FaviconPath != "":
+		w.Header().Set("Content-type", "image/x-icon")
+		http.ServeFile(w, r, s.cfg.FaviconPath)
+		return
+	case p == "/site.webmanifest" && s.cfg.WebManifest != "":
+		w.Header().Set("Content-type", "application/manifest+json; charset=utf-8")
+		http.ServeFile(w, r, s.cfg.WebManifest)
+		return
+	case p == "/sitemap.xml" && !s.cfg.DisableSitemap:
+		w.Header().Set("Content-type", "application/xml; charset=utf-8")
+		w.Write(s.sitemap)
+		return
+	case p == "/feed.xsl" && s.cfg.FeedStylesheet != "":
+		w.Header().Set("Content-type", "text/xsl; charset=utf-8")
+		http.ServeFile(w, r, s.cfg.FeedStylesheet)
+		return
+	case strings.HasPrefix(p, "/author/") && strings.HasSuffix(p, "/feed.atom"):
+		feed, ok := s.authorFeeds[strings.TrimSuffix(strings.TrimPrefix(p, "/author/"), "/feed.atom")]
+		if !ok || s.cfg.DisableAtom {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-type", "application/atom+xml; charset=utf-8")
+		w.Write(feed)
+		return
+	case p == "/feed.rss":
+		if !s.cfg.EnableRSS {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-type", "application/rss+xml; charset=utf-8")
+		w.Write(s.rssFeed)
+		return
+	case p == "/.json":
+		if s.cfg.DisableJSON {
+			http.NotFound(w, r)
+			return
+		}
 		if p := r.FormValue("jsonp"); validJSONPFunc.MatchString(p) {
 			w.Header().Set("Content-type", "application/javascript; charset=utf-8")
 			fmt.Fprintf(w, "%v(%s)", p, s.jsonFeed)
@@ -185,233 +1341,1823 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-type", "application/json; charset=utf-8")
 		w.Write(s.jsonFeed)
 		return
-	default:
+	case strings.HasPrefix(p, "/tag/") && strings.HasSuffix(p, ".json"):
+		feed, ok := s.tagFeedsJSON[strings.TrimSuffix(strings.TrimPrefix(p, "/tag/"), ".json")]
+		if !ok || !s.cfg.EnableJSONFeed {
+			http.NotFound(w, r)
+			return
+		}
+		if p := r.FormValue("jsonp"); p != "" {
+			if !validJSONPFunc.MatchString(p) {
+				http.Error(w, "invalid jsonp callback", http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-type", "application/javascript; charset=utf-8")
+			fmt.Fprintf(w, "%v(%s)", p, feed)
+			return
+		}
+		w.Header().Set("Content-type", "application/feed+json; charset=utf-8")
+		w.Write(feed)
+		return
+	case p == "/related.json":
+		doc, ok := s.docPaths[strings.TrimPrefix(r.FormValue("path"), s.cfg.BasePath)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		data, err := json.Marshal(relatedItems(doc))
+		if err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if p := r.FormValue("jsonp"); p != "" {
+			if !validJSONPFunc.MatchString(p) {
+				http.Error(w, "invalid jsonp callback", http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-type", "application/javascript; charset=utf-8")
+			fmt.Fprintf(w, "%v(%s)", p, data)
+			return
+		}
+		w.Header().Set("Content-type", "application/json; charset=utf-8")
+		w.Write(data)
+		return
+	case p == "/feed.json":
+		if !s.cfg.EnableJSONFeed {
+			http.NotFound(w, r)
+			return
+		}
+		if p := r.FormValue("jsonp"); p != "" {
+			if !validJSONPFunc.MatchString(p) {
+				http.Error(w, "invalid jsonp callback", http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-type", "application/javascript; charset=utf-8")
+			fmt.Fprintf(w, "%v(%s)", p, s.feedJSON)
+			return
+		}
+		w.Header().Set("Content-type", "application/feed+json; charset=utf-8")
+		w.Write(s.feedJSON)
+		return
+	case strings.HasSuffix(p, "/og.png") && s.docPaths[strings.TrimSuffix(p, "/og.png")] != nil:
+		img, err := s.ogImage(strings.TrimSuffix(p, "/og.png"), s.docPaths[strings.TrimSuffix(p, "/og.png")])
+		if err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-type", "image/png")
+		w.Header().Set("Content-Length", strconv.Itoa(len(img)))
+		w.Write(img)
+		return
+	default:
+		if s.cfg.TrailingSlash {
+			if doc, found := s.docPaths[p]; found {
+				target := s.postURL(doc)
+				if r.URL.RawQuery != "" {
+					target += "?" + r.URL.RawQuery
+				}
+				http.Redirect(w, r, target, http.StatusMovedPermanently)
+				return
+			}
+			p = strings.TrimSuffix(p, "/")
+		}
 		doc, ok := s.docPaths[p]
+		if !ok {
+			if canonical := s.looseDoc(p); canonical != nil {
+				target := s.postURL(canonical)
+				if r.URL.RawQuery != "" {
+					target += "?" + r.URL.RawQuery
+				}
+				http.Redirect(w, r, target, http.StatusMovedPermanently)
+				return
+			}
+		}
+		// Snippets are also served as plain text, at their path with a .txt
+		// extension or to clients preferring text/plain.
+		if !ok && strings.HasSuffix(p, ".txt") {
+			if snippet, found := s.docPaths[strings.TrimSuffix(p, ".txt")]; found && snippet.Kind == "snippet" {
+				serveSnippet(w, snippet)
+				return
+			}
+		}
+		if ok && doc.Kind == "snippet" {
+			w.Header().Set("Vary", "Accept")
+			if prefersPlainText(r) {
+				serveSnippet(w, doc)
+				return
+			}
+		}
+		// Section indexes are served without a trailing slash.
+		if index, found := s.docPaths[strings.TrimSuffix(p, "/")]; !ok && found && index.SectionIndex {
+			http.Redirect(w, r, index.Path, http.StatusMovedPermanently)
+			return
+		}
+		if target := s.feedAlias(p); !ok && target != "" {
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+		if !ok && s.gone[p] {
+			if s.template.gone == nil {
+				http.Error(w, http.StatusText(http.StatusGone), http.StatusGone)
+				return
+			}
+			t, status = s.template.gone, http.StatusGone
+			break
+		}
+		if !ok && s.cfg.StaticPrefix != "" && !strings.HasPrefix(p, s.cfg.StaticPrefix+"/") {
+			if s.template.notFound == nil {
+				http.NotFound(w, r)
+				return
+			}
+			t, status = s.template.notFound, http.StatusNotFound
+			break
+		}
 		if !ok {
 			// Not a doc; try to just serve static articles.
 			s.content.ServeHTTP(w, r)
 			return
 		}
-		d.Doc = doc
+		d = s.articleData(doc)
+		if d.Robots != "" {
+			w.Header().Set("X-Robots-Tag", d.Robots)
+		}
 		t = s.template.article
+		if s.cfg.CountViews && r.Method == http.MethodGet {
+			s.recordView(p)
+		}
+		if page, ok := s.cachedPage(p); ok && s.cachePages() {
+			w.Header().Set("Content-type", "text/html; charset=utf-8")
+			w.Header().Set("Content-Length", strconv.Itoa(len(page)))
+			w.Write(page)
+			return
+		}
 	}
-	err := t.ExecuteTemplate(w, "root", d)
+	if d.Robots != "" {
+		w.Header().Set("X-Robots-Tag", d.Robots)
+	}
+	if s.cfg.ContentSecurityPolicy != "" {
+		nonce, err := newNonce()
+		if err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		d.Nonce = nonce
+		w.Header().Set("Content-Security-Policy", strings.ReplaceAll(s.cfg.ContentSecurityPolicy, "{nonce}", nonce))
+	}
+
+	// Render into a buffer so a failing template never yields a partial page.
+	// Pages showing an announcement are not cached, as it may change or expire.
+	var b bytes.Buffer
+
+	cacheable := s.announcement() == ""
+
+	err := t.ExecuteTemplate(&b, "root", d)
 	if err != nil {
+		if s.cfg.OnError != nil {
+			s.cfg.OnError(w, r, err)
+			return
+		}
 		log.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if cacheable && d.Doc != nil && status == http.StatusOK && s.cachePages() {
+		s.storePage(p, bytes.Clone(b.Bytes()))
+	}
+
+	if cacheable && s.template.all != nil && t == s.template.all {
+		s.storeAllPage(bytes.Clone(b.Bytes()))
+	}
+
+	w.Header().Set("Content-type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(b.Len()))
+	w.WriteHeader(status)
+	b.WriteTo(w)
+}
+
+// ServeSnippet: writes the code of the snippet Doc (Article) as plain text.
+
+func serveSnippet(w http.ResponseWriter, doc *Doc) {
+	w.Header().Set("Content-type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(doc.Raw)))
+	io.WriteString(w, doc.Raw)
+}
+
+// PrefersPlainText: reports whether the request accepts text/plain but not HTML.
+
+func prefersPlainText(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+
+	return strings.Contains(accept, "text/plain") && !strings.Contains(accept, "text/html")
+}
+
+// NewNonce: returns a random, base64-encoded content security policy nonce.
+
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// Paged: returns the Docs (Articles) on the page named by the ?page= value, of
+// size articles per page, along with the page number and page count. An empty
+// value is the first page; ok is false if the value names no page.
+
+func paged(docs []*Doc, size int, value string) (_ []*Doc, page, pages int, ok bool) {
+	page = 1
+
+	if value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, 0, 0, false
+		}
+		page = n
+	}
+
+	if size <= 0 {
+		return docs, 1, 1, page == 1
+	}
+
+	pages = (len(docs) + size - 1) / size
+	if pages == 0 {
+		pages = 1
+	}
+
+	if page < 1 || page > pages {
+		return nil, 0, 0, false
+	}
+
+	start := (page - 1) * size
+	end := start + size
+	if end > len(docs) {
+		end = len(docs)
+	}
+
+	return docs[start:end], page, pages, true
+}
+
+// LoadReport: returns the report of the last load, including a failed Reload.
+
+func (s *Server) LoadReport() LoadReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.report
+}
+
+// LoadDuration: returns the total time taken by the last load, including
+// parsing the templates in NewServer.
+
+func (s *Server) LoadDuration() time.Duration {
+	t := s.LoadTimings()
+
+	return t.Templates + t.Content + t.Feeds
+}
+
+// LoadTimings: returns the durations of the phases of the last load.
+
+func (s *Server) LoadTimings() LoadTimings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.timings
+}
+
+// DocsInRange: returns the articles dated within [from, to), newest first.
+
+func (s *Server) DocsInRange(from, to time.Time) []*Doc {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var docs []*Doc
+
+	for _, doc := range s.docs {
+		if !doc.Time.Before(from) && doc.Time.Before(to) {
+			docs = append(docs, doc)
+		}
+	}
+
+	return docs
+}
+
+// RenderDoc: renders the article at path (relative to the BasePath) to w using
+// the article template, as it would be served.
+
+func (s *Server) RenderDoc(w io.Writer, path string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	doc, ok := s.docPaths[path]
+	if !ok {
+		return fmt.Errorf("blog: no article at path %q", path)
+	}
+
+	return s.template.article.ExecuteTemplate(w, "root", s.articleData(doc))
+}
+
+// ArticleData: returns the data the article template renders the page of the
+// Doc (Article) from, whether it is served, warmed or rendered by RenderDoc.
+
+func (s *Server) articleData(doc *Doc) rootData {
+	d := rootData{Doc: doc, BasePath: s.cfg.BasePath}
+
+	if doc.NoIndex {
+		d.Robots = "noindex"
+	}
+
+	return d
+}
+
+// LoadDocs: reads all articles of the content file system and renders all the
+// articles it finds. Articles whose content hash matches the previous load
+// are reused rather than parsed and rendered again. The paths of the articles
+// that were added, updated or removed are returned. Every failing article is
+// recorded in the load report before the load fails.
+
+func (s *Server) loadDocs() ([]string, error) {
+	// Read articles into docs (article) field.
+	exts := make(map[string]bool)
+
+	for _, ext := range s.extensions() {
+		exts[ext] = true
+	}
+
+	var (
+		docs   []*Doc
+		report LoadReport
+	)
+
+	sources := make(map[string]docSource)
+
+	includes, err := s.includesDigest()
+	if err != nil {
+		return nil, err
+	}
+
+	fn := func(p string, entry fs.DirEntry, err error) error {
+		if p != "." && s.ignored(p) {
+			if entry != nil && entry.IsDir() {
+				return fs.SkipDir
+			}
+
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		ext := path.Ext(p)
+
+		if !exts[ext] || entry == nil || entry.IsDir() {
+			return nil
+		}
+
+		file := s.sourceName(p)
+		source := p
+
+		b, err := fs.ReadFile(s.contentFS, p)
+
+		if err != nil {
+			return err
+		}
+
+		hash := sha256.Sum256(append(b, includes...))
+
+		// Unchanged articles are reused as copies, since the load goes on to
+		// update them and may yet fail.
+		if src, ok := s.sources[file]; ok && src.hash == hash {
+			doc := *src.doc
+			docs = append(docs, &doc)
+			sources[file] = docSource{hash: hash, doc: &doc, warnings: src.warnings}
+			report.Warnings = append(report.Warnings, src.warnings...)
+			return nil
+		}
+
+		d, err := parsers[ext](bytes.NewReader(b), file)
+
+		if err != nil {
+			report.Errors = append(report.Errors, LoadError{File: file, Err: err})
+			return nil
+		}
+
+		var warnings []LoadError
+
+		// An article the name gives no date to is still served, undated.
+		if d.Time.IsZero() && s.cfg.DateFromFilename != "" {
+			d.Time, err = dateFromFilename(path.Base(p), s.cfg.DateFromFilename)
+			if err != nil {
+				warnings = append(warnings, LoadError{File: file, Err: err})
+			}
+		}
+
+		report.Warnings = append(report.Warnings, warnings...)
+
+		if metadataBool(d, "draft") {
+			report.Drafts++
+			return nil
+		}
+
+		err = s.expandIncludes(d.Sections)
+		if err != nil {
+			report.Errors = append(report.Errors, LoadError{File: file, Err: err})
+			return nil
+		}
+
+		if s.cfg.RenderTables {
+			renderTables(d.Sections)
+		}
+
+		marked, more := s.markMore(d)
+
+		html := new(bytes.Buffer)
+
+		err = marked.Render(html, s.template.doc)
+		if err != nil {
+			report.Errors = append(report.Errors, LoadError{File: file, Err: err})
+			return nil
+		}
+
+		rendered := html.String()
+
+		if s.cfg.RewriteRelativeLinks || s.cfg.TrailingSlash {
+			rendered = rewriteRelative(rendered, s.cfg.BasePath+path.Dir("/"+p))
+		}
+
+		if s.cfg.AssetRewrite != nil {
+			rendered = rewriteAssets(rendered, s.cfg.AssetRewrite)
+		}
+
+		if s.cfg.MarkLede {
+			class := s.cfg.LedeClass
+			if class == "" {
+				class = "lede"
+			}
+
+			rendered = markLede(rendered, class)
+		}
+
+		// Themes may drop the marker along with the paragraph.
+		more = more && strings.Contains(rendered, moreMarker)
+		if more {
+			rendered = strings.Replace(rendered, moreMarker, `<span id="`+moreID(source)+`"></span>`, 1)
+		}
+
+		p = "/" + strings.TrimSuffix(p, ext) // Root and trim extension.
+
+		if s.cfg.SlugifyFilenames {
+			p = slugifyPath(p)
+		}
+
+		// An explicit slug replaces the file name.
+		if slug := d.Metadata["slug"]; slug != "" {
+			p = path.Join(path.Dir(p), Slugify(slug))
+		}
+
+		// The index article of a directory stands for the directory itself.
+		sectionIndex := s.cfg.SectionIndexes && d.Metadata["slug"] == "" &&
+			path.Base(strings.TrimSuffix(source, ext)) == "index" && path.Dir(source) != "."
+
+		if sectionIndex {
+			p = path.Dir(p)
+		}
+
+		log.Printf("%v", d)
+
+		text := stripTags(rendered)
+
+		doc := &Doc{
+			Doc:          d,
+			Intro:        d.Intro,
+			Image:        d.Image,
+			Category:     d.Category,
+			Description:  d.Metadata["description"],
+			Canonical:    d.Metadata["canonical"],
+			Rights:       d.Metadata["rights"],
+			NoIndex:      metadataBool(d, "noindex"),
+			NoFeed:       d.Metadata["feed"] != "" && !metadataBool(d, "feed"),
+			Source:       source,
+			slugPath:     p,
+			HTML:         template.HTML(rendered),
+			Math:         s.cfg.MathJax && mathExpr.MatchString(rendered),
+			Summary:      s.summary(d),
+			More:         more,
+			Kind:         d.Metadata["kind"],
+			WordCount:    len(strings.Fields(text)),
+			Chars:        utf8.RuneCountInString(text),
+			SectionIndex: sectionIndex,
+		}
+
+		if v := d.Metadata["updated"]; v != "" {
+			doc.Updated, err = parseUpdated(v)
+			if err != nil {
+				report.Errors = append(report.Errors, LoadError{File: file, Err: err})
+				return nil
+			}
+		}
+
+		if v := d.Metadata["order"]; v != "" {
+			doc.Order, err = strconv.Atoi(v)
+			if err != nil {
+				err = fmt.Errorf("blog: order %q is not an integer", v)
+				report.Errors = append(report.Errors, LoadError{File: file, Err: err})
+				return nil
+			}
+		}
+
+		doc.Contributors = metadataList(d, "contributors")
+		doc.Styles = metadataList(d, "styles")
+		doc.Scripts = metadataList(d, "scripts")
+
+		for _, urls := range [][]string{doc.Styles, doc.Scripts} {
+			for _, u := range urls {
+				if !includableURL(u) {
+					err = fmt.Errorf("blog: style or script URL %q must be absolute or site-rooted", u)
+					report.Errors = append(report.Errors, LoadError{File: file, Err: err})
+					return nil
+				}
+			}
+		}
+
+		if s.cfg.ShowNotes {
+			doc.Notes = docNotes(d)
+		}
+
+		if doc.Kind == "snippet" {
+			doc.Raw = snippetText(d.Sections)
+		}
+
+		docs = append(docs, doc)
+		sources[file] = docSource{hash: hash, doc: doc, warnings: warnings}
+
+		return nil
+	}
+
+	err = fs.WalkDir(s.contentFS, ".", fn)
+	if err != nil {
+		return nil, err
+	}
+
+	report.Errors = append(report.Errors, s.resolveSlugCollisions(docs)...)
+
+	if len(report.Errors) > 0 {
+		s.report = report
+
+		errs := make([]error, len(report.Errors))
+		for i, e := range report.Errors {
+			errs[i] = e
+		}
+
+		return nil, errors.Join(errs...)
+	}
+
+	now := time.Now()
+
+	for _, doc := range docs {
+		if doc.Time.After(now) {
+			report.Future++
+		}
+	}
+
+	report.Published = len(docs)
+	s.report = report
+
+	sort.Sort(docsByTime(docs))
+
+	// Collect the paths of added, updated and removed articles.
+	var changed []string
+
+	for file, src := range sources {
+		if old, ok := s.sources[file]; !ok || old.hash != src.hash {
+			changed = append(changed, src.doc.Path)
+		}
+	}
+
+	for file, old := range s.sources {
+		if _, ok := sources[file]; !ok {
+			changed = append(changed, old.doc.Path)
+		}
+	}
+
+	sort.Strings(changed)
+
+	s.docs = docs
+	s.sources = sources
+
+	// Hidden section indexes are served, but left out of everything else.
+	if s.cfg.HideSectionIndexes {
+		s.docs = make([]*Doc, 0, len(docs))
+
+		for _, doc := range docs {
+			if !doc.SectionIndex {
+				s.docs = append(s.docs, doc)
+			}
+		}
+	}
+
+	// Pull out doc (article) paths and tags and put in reverse-associating maps.
+	s.docPaths = make(map[string]*Doc)
+	s.docTags = make(map[string][]*Doc)
+	s.docAuthors = make(map[string][]*Doc)
+
+	for _, d := range docs {
+		s.docPaths[strings.TrimPrefix(d.Path, s.cfg.BasePath)] = d
+	}
+
+	s.docFolded = nil
+
+	if s.cfg.CaseInsensitiveURLs {
+		s.docFolded = make(map[string]*Doc)
+
+		// Paths differing only in case go to the newest article.
+		for _, d := range docs {
+			p := strings.ToLower(strings.TrimPrefix(d.Path, s.cfg.BasePath))
+
+			if other, ok := s.docFolded[p]; ok {
+				err := fmt.Errorf("blog: path %s differs only in case from %s", d.Path, other.Path)
+				s.report.Warnings = append(s.report.Warnings, LoadError{File: d.Source, Err: err})
+				continue
+			}
+
+			s.docFolded[p] = d
+		}
+	}
+
+	for _, d := range s.docs {
+		for _, t := range d.Tags {
+			s.docTags[t] = append(s.docTags[t], d)
+		}
+		for _, a := range d.Authors {
+			if name := Slugify(authorName(a)); name != "" {
+				s.docAuthors[name] = append(s.docAuthors[name], d)
+			}
+		}
+	}
+
+	// Pull out unique sorted list of tags, the most used first if so configured.
+	s.tags = nil
+
+	for t := range s.docTags {
+		s.tags = append(s.tags, t)
+	}
+
+	sort.Strings(s.tags)
+
+	if s.cfg.TagOrder == "count" {
+		sort.SliceStable(s.tags, func(i, j int) bool {
+			return len(s.docTags[s.tags[i]]) > len(s.docTags[s.tags[j]])
+		})
+	}
+
+	// Articles by the last element of their path, for the "next" metadata.
+	bySlug := make(map[string]*Doc, len(s.docs))
+
+	for _, doc := range s.docs {
+		bySlug[Slugify(path.Base(doc.Path))] = doc
+	}
+
+	// Reading order of Newer and Older; s.docs itself stays newest first.
+	sequence := s.docs
+
+	if s.cfg.SortOrder == "order-field" {
+		sequence = append([]*Doc(nil), s.docs...)
+
+		sort.SliceStable(sequence, func(i, j int) bool {
+			oi, oj := sequence[i].Metadata["order"] != "", sequence[j].Metadata["order"] != ""
+			if oi != oj {
+				return oi
+			}
+
+			return sequence[i].Order < sequence[j].Order
+		})
+	}
+
+	// Setup presentation-related fields, Newer, Older, and Related.
+	for _, doc := range s.docs {
+		doc.Related, doc.Newer, doc.Older = nil, nil, nil
+		doc.RecommendedNext = nil
+		doc.SectionNewer, doc.SectionOlder = nil, nil
+		doc.Stale = s.isStale(doc)
+
+		// Newer, Older: docs adjacent to Doc (Article) in the reading order.
+		for i := range sequence {
+			if sequence[i] != doc {
+				continue
+			}
+
+			if i > 0 {
+				doc.Newer = sequence[i-1]
+			}
+
+			if i+1 < len(sequence) {
+				doc.Older = sequence[i+1]
+			}
+
+			break
+		}
+
+		// Related: all docs (articles) that share tags with doc.
+		related := make(map[*Doc]bool)
+
+		for _, t := range doc.Tags {
+			for _, d := range s.docTags[t] {
+				if d != doc && s.recentEnough(doc, d) {
+					related[d] = true
+				}
+			}
+		}
+
+		for d := range related {
+			doc.Related = append(doc.Related, d)
+		}
+
+		if len(doc.Related) == 0 && s.cfg.RelatedFallbackByDate {
+			for _, d := range s.nearestDocs(doc, relatedFallbackDocs) {
+				if s.recentEnough(doc, d) {
+					doc.Related = append(doc.Related, d)
+				}
+			}
+		}
+
+		sort.Sort(docsByTime(doc.Related))
+
+		// RecommendedNext: the article named by the "next" metadata.
+		if next := doc.Metadata["next"]; next != "" {
+			if d := bySlug[Slugify(next)]; d != nil && d != doc {
+				doc.RecommendedNext = d
+			} else {
+				err := fmt.Errorf("blog: next article %q not found", next)
+				s.report.Warnings = append(s.report.Warnings, LoadError{File: doc.Source, Err: err})
+			}
+		}
+	}
+
+	// SectionNewer, SectionOlder: docs adjacent to Doc (Article) in its directory.
+	sections := make(map[string][]*Doc)
+
+	for _, doc := range sequence {
+		if doc.SectionIndex {
+			continue
+		}
+
+		dir := path.Dir(doc.Path)
+		sections[dir] = append(sections[dir], doc)
+	}
+
+	for _, docs := range sections {
+		for i, doc := range docs {
+			if i > 0 {
+				doc.SectionNewer = docs[i-1]
+			}
+
+			if i+1 < len(docs) {
+				doc.SectionOlder = docs[i+1]
+			}
+		}
+	}
+
+	return changed, nil
+}
+
+// RecentEnough: reports whether d is recent enough to be related to doc, that is
+// not older than the RelatedMaxAge before doc.
+
+func (s *Server) recentEnough(doc, d *Doc) bool {
+	if s.cfg.RelatedMaxAge <= 0 {
+		return true
+	}
+
+	return !d.Time.Before(doc.Time.Add(-s.cfg.RelatedMaxAge))
+}
+
+// Ignored: reports whether the file or directory at p, relative to the content
+// file system, matches one of the IgnorePatterns. Patterns are matched against
+// both the base name and the whole relative path.
+
+func (s *Server) ignored(p string) bool {
+	for _, pattern := range s.cfg.IgnorePatterns {
+		if ok, _ := path.Match(pattern, path.Base(p)); ok {
+			return true
+		}
+
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NearestDocs: returns up to n Docs (Articles) closest in time to doc, widening
+// outwards from its immediate Newer and Older neighbours.
+
+func (s *Server) nearestDocs(doc *Doc, n int) []*Doc {
+	var i int
+
+	for i = range s.docs {
+		if s.docs[i] == doc {
+			break
+		}
+	}
+
+	var docs []*Doc
+
+	newer, older := i-1, i+1
+
+	for len(docs) < n && (newer >= 0 || older < len(s.docs)) {
+		switch {
+		case newer < 0:
+			docs = append(docs, s.docs[older])
+			older++
+		case older >= len(s.docs):
+			docs = append(docs, s.docs[newer])
+			newer--
+		case s.docs[newer].Time.Sub(doc.Time) <= doc.Time.Sub(s.docs[older].Time):
+			docs = append(docs, s.docs[newer])
+			newer--
+		default:
+			docs = append(docs, s.docs[older])
+			older++
+		}
+	}
+
+	return docs
+}
+
+// RenderAtomFeed: generates an XML Atom feed and stores it in the Server's atomFeed field.
+// Articles beyond the feed are rendered into RFC 5005 archive documents, the oldest
+// being page 1 so that full pages remain stable as articles are published.
+
+func (s *Server) renderAtomFeed() error {
+	n := s.atomArticles()
+	docs := s.feedDocs()
+
+	recent := docs
+	if len(recent) > n {
+		recent = recent[:n]
+	}
+
+	archive := docs[len(recent):]
+
+	var pages int
+
+	if n > 0 {
+		pages = (len(archive) + n - 1) / n
+	}
+
+	feed := s.atomFeedFor(recent, s.mountedURL("/feed.atom"))
+
+	if pages > 0 {
+		feed.Link = append(feed.Link, atom.Link{Rel: "prev-archive", Href: s.atomArchiveURL(pages)})
+	}
+
+	next := s.cfg.FeedNextURL
+	if next == "" && !s.cfg.DisableIndex {
+		next = s.mountedURL(s.cfg.IndexPath)
+	}
+
+	if next != "" {
+		feed.Link = append(feed.Link, atom.Link{Rel: "next", Href: next})
+	}
+
+	data, err := s.marshalAtom(feed)
+	if err != nil {
+		return err
+	}
+
+	s.atomFeed = data
+	s.atomArchive = nil
+
+	for page := 1; page <= pages; page++ {
+		end := len(archive) - (page-1)*n
+		start := end - n
+		if start < 0 {
+			start = 0
+		}
+
+		feed := s.atomFeedFor(archive[start:end], s.atomArchiveURL(page))
+		// Archive pages are never complete (RFC 5005 §2): readers would drop
+		// every article missing from the page.
+		feed.Archive = &struct{}{}
+
+		feed.Link = append(feed.Link, atom.Link{Rel: "current", Href: s.mountedURL("/feed.atom")})
+
+		if page > 1 {
+			feed.Link = append(feed.Link, atom.Link{Rel: "prev-archive", Href: s.atomArchiveURL(page - 1)})
+		}
+
+		if page < pages {
+			feed.Link = append(feed.Link, atom.Link{Rel: "next-archive", Href: s.atomArchiveURL(page + 1)})
+		}
+
+		data, err := s.marshalAtom(feed)
+		if err != nil {
+			return err
+		}
+
+		s.atomArchive = append(s.atomArchive, data)
+	}
+
+	return nil
+}
+
+// RenderAuthorFeeds: generates an ATOM feed of the articles of each author and
+// stores them in the Server's authorFeeds field.
+
+func (s *Server) renderAuthorFeeds() error {
+	s.authorFeeds = make(map[string][]byte)
+
+	for name, docs := range s.docAuthors {
+		docs = syndicated(docs, 0)
+		if len(docs) == 0 {
+			continue
+		}
+
+		if n := s.atomArticles(); len(docs) > n {
+			docs = docs[:n]
+		}
+
+		feed := s.atomFeedFor(docs, s.mountedURL("/author/"+name+"/feed.atom"))
+		feed.ID += ":author/" + name // Entries keep the IDs they have in the main feed.
+		for _, a := range docs[0].Authors {
+			if Slugify(authorName(a)) == name {
+				feed.Title = s.cfg.FeedTitle + " - " + authorName(a)
+				break
+			}
+		}
+
+		data, err := s.marshalAtom(feed)
+		if err != nil {
+			return err
+		}
+
+		s.authorFeeds[name] = data
+	}
+
+	return nil
+}
+
+// MarshalAtom: marshals the ATOM feed, preceded by a reference to the feed
+// stylesheet when FeedStylesheet is set.
+
+func (s *Server) marshalAtom(feed *atom.Feed) ([]byte, error) {
+	data, err := xml.Marshal(feed)
+	if err != nil || s.cfg.FeedStylesheet == "" {
+		return data, err
+	}
+
+	pi := fmt.Sprintf(`<?xml-stylesheet type="text/xsl" href="%s/feed.xsl"?>`, s.cfg.BasePath)
+
+	return append([]byte(xml.Header+pi+"\n"), data...), nil
+}
+
+// AtomSummary: returns the ATOM summary of the Doc (Article) in the configured type.
+
+func (s *Server) atomSummary(doc *Doc) *atom.Text {
+	if s.cfg.FeedSummaryType == "text" || s.cfg.SummaryPlainText {
+		return &atom.Text{Type: "text", Body: plainSummary(doc)}
+	}
+
+	return &atom.Text{Type: "html", Body: string(s.excerpt(doc))}
+}
+
+// FeedSummary: returns the summary of the Doc (Article) for the JSON feeds, as
+// plain text when SummaryPlainText is set.
+
+func (s *Server) feedSummary(doc *Doc) string {
+	if s.cfg.SummaryPlainText {
+		return plainSummary(doc)
+	}
+
+	return string(doc.Summary)
+}
+
+// RSSDescription: returns the description of the Doc (Article) for the RSS feed,
+// its excerpt or, when SummaryPlainText is set, its escaped plain summary.
+
+func (s *Server) rssDescription(doc *Doc) string {
+	if s.cfg.SummaryPlainText {
+		return template.HTMLEscapeString(plainSummary(doc))
+	}
+
+	return string(s.excerpt(doc))
+}
+
+// PlainSummary: returns the summary of the Doc (Article) as plain text on a
+// single line.
+
+func plainSummary(doc *Doc) string {
+	return strings.Join(strings.Fields(stripTags(string(doc.Summary))), " ")
+}
+
+// FeedDocs: returns the Docs (Articles) of the main feeds, those with at least
+// FeedMinWords words.
+
+func (s *Server) feedDocs() []*Doc {
+	return syndicated(s.docs, s.cfg.FeedMinWords)
+}
+
+// Syndicated: returns the Docs (Articles) that belong in feeds, those not marked
+// NoFeed that have at least minWords words.
+
+func syndicated(docs []*Doc, minWords int) []*Doc {
+	var feedDocs []*Doc
+
+	for _, doc := range docs {
+		if !doc.NoFeed && doc.WordCount >= minWords {
+			feedDocs = append(feedDocs, doc)
+		}
+	}
+
+	return feedDocs
+}
+
+// AtomArticles: returns the amount of Articles on the ATOM feed.
+
+func (s *Server) atomArticles() int {
+	if s.cfg.AtomArticles > 0 {
+		return s.cfg.AtomArticles
+	}
+
+	return s.cfg.FeedArticles
+}
+
+// JSONArticles: returns the amount of Articles on the JSON feeds.
+
+func (s *Server) jsonArticles() int {
+	if s.cfg.JSONArticles > 0 {
+		return s.cfg.JSONArticles
+	}
+
+	return s.cfg.FeedArticles
+}
+
+// AtomArchiveURL: returns the URL of the numbered Atom archive document.
+
+func (s *Server) atomArchiveURL(page int) string {
+	return s.mountedURL("/feed.atom?page=" + strconv.Itoa(page))
+}
+
+// MountedURL: returns the absolute URL of the path p served by the Server,
+// which is mounted at the BasePath. Every absolute URL the Server produces is
+// built by it.
+
+func (s *Server) mountedURL(p string) string {
+	return s.cfg.BaseURL + s.cfg.BasePath + p
+}
+
+// AtomFeedFor: builds an Atom feed of the provided Docs (Articles) whose self link is self.
+
+func (s *Server) atomFeedFor(docs []*Doc, self string) *atom.Feed {
+	var updated time.Time
+
+	if len(docs) > 0 {
+		updated = docs[0].Time
+	}
+
+	feed := &atom.Feed{
+		Title:   s.cfg.FeedTitle,
+		ID:      "tag:" + s.cfg.Hostname + ",2013:" + s.cfg.Hostname,
+		Updated: atom.Time(updated),
+		Rights:  s.cfg.FeedRights,
+		Link: []atom.Link{{
+			Rel:  "self",
+			Href: self,
+		}},
+		Generator: &atom.Generator{
+			Name: defaultGeneratorName,
+			URI:  defaultGeneratorURI,
+		},
+	}
+
+	if s.cfg.GeneratorName != "" {
+		feed.Generator = &atom.Generator{Name: s.cfg.GeneratorName, URI: s.cfg.GeneratorURI}
+	}
+
+	if s.cfg.FeedAuthorName != "" {
+		feed.Author = &atom.Person{Name: s.cfg.FeedAuthorName, Email: s.cfg.FeedAuthorEmail}
+	}
+
+	for _, doc := range docs {
+		// The ID derives from the path so it is unaffected by the BasePath.
+		e := &atom.Entry{
+			Title: doc.Title,
+			ID:    feed.ID + strings.TrimPrefix(doc.Path, s.cfg.BasePath),
+			Link: []atom.Link{{
+				Rel:  "alternative",
+				Href: canonical(doc),
+			}},
+			Published: atom.Time(doc.Time),
+			Updated:   atom.Time(lastChanged(doc)),
+			Rights:    doc.Rights,
+			Summary:   s.atomSummary(doc),
+			Content: &atom.Text{
+				Type: "html",
+				Body: string(doc.HTML),
+			},
+		}
+
+		// Entries without authors fall back to the feed's, if any.
+		if name := authors(doc.Authors); name != "" {
+			e.Author = &atom.Person{Name: name, URI: soleAuthorURL(doc.Authors)}
+		} else if feed.Author != nil {
+			e.Author = feed.Author
+		}
+
+		for _, name := range doc.Contributors {
+			e.Contributor = append(e.Contributor, &atom.Person{Name: name})
+		}
+
+		feed.Entry = append(feed.Entry, e)
+	}
+
+	return feed
+}
+
+// RenderJSONFeed: generates a JSON feed and stores it in the Server's jsonFeed field.
+
+func (s *Server) renderJSONFeed() error {
+	var feed []jsonItem
+
+	for i, doc := range s.feedDocs() {
+		if i >= s.jsonArticles() {
+			break
+		}
+
+		item := jsonItem{
+			Title:   doc.Title,
+			Link:    doc.Permalink,
+			Time:    doc.Time,
+			Summary: s.feedSummary(doc),
+			Content: string(doc.HTML),
+			Author:  authors(doc.Authors),
+		}
+
+		feed = append(feed, item)
+	}
+
+	data, err := json.Marshal(feed)
+
+	if err != nil {
+		return err
+	}
+
+	s.jsonFeed = data
+	return nil
+}
+
+// RenderFeedJSON: generates a spec-compliant JSON Feed and stores it in the Server's feedJSON field.
+
+func (s *Server) renderFeedJSON() error {
+	data, err := s.feedJSONFor(s.feedDocs(), s.cfg.FeedTitle, s.mountedURL("/feed.json"))
+	if err != nil {
+		return err
+	}
+
+	s.feedJSON = data
+	return nil
+}
+
+// RenderTagFeedsJSON: generates a JSON Feed of the articles of each tag and
+// stores them in the Server's tagFeedsJSON field.
+
+func (s *Server) renderTagFeedsJSON() error {
+	s.tagFeedsJSON = make(map[string][]byte)
+
+	for tag, docs := range s.docTags {
+		data, err := s.feedJSONFor(syndicated(docs, 0), s.cfg.FeedTitle+" - "+s.tagMeta(tag).Title, s.mountedURL("/tag/"+url.PathEscape(tag)+".json"))
+		if err != nil {
+			return err
+		}
+
+		s.tagFeedsJSON[tag] = data
+	}
+
+	return nil
+}
+
+// FeedJSONFor: returns the JSON Feed (jsonfeed.org) of the given Docs (Articles),
+// served at feedURL.
+
+func (s *Server) feedJSONFor(docs []*Doc, title, feedURL string) ([]byte, error) {
+	feed := jsonfeed.Feed{
+		Version:     jsonfeed.Version,
+		Title:       title,
+		HomePageURL: s.mountedURL("/"),
+		FeedURL:     feedURL,
+		Rights:      s.cfg.FeedRights,
+		Items:       []*jsonfeed.Item{},
+	}
+
+	if s.cfg.FeedAuthorName != "" {
+		feed.Authors = []*jsonfeed.Author{{Name: s.cfg.FeedAuthorName}}
+	}
+
+	for i, doc := range docs {
+		if i >= s.jsonArticles() {
+			break
+		}
+
+		item := &jsonfeed.Item{
+			ID:            doc.Permalink,
+			URL:           canonical(doc),
+			Title:         doc.Title,
+			ContentHTML:   string(doc.HTML),
+			Summary:       s.feedSummary(doc),
+			Image:         doc.Image,
+			DatePublished: doc.Time,
+			Tags:          doc.Tags,
+			Rights:        doc.Rights,
+		}
+
+		if name := authors(doc.Authors); name != "" {
+			item.Authors = []*jsonfeed.Author{{Name: name, URL: soleAuthorURL(doc.Authors)}}
+		} else {
+			item.Authors = feed.Authors
+		}
+
+		feed.Items = append(feed.Items, item)
+	}
+
+	return json.Marshal(&feed)
+}
+
+// RenderRSSFeed: generates an RSS 2.0 feed and stores it in the Server's rssFeed field.
+
+func (s *Server) renderRSSFeed() error {
+	channel := &rss.Channel{
+		Title:       s.cfg.FeedTitle,
+		Link:        s.mountedURL("/"),
+		Description: s.cfg.FeedTitle,
+		Copyright:   s.cfg.FeedRights,
+	}
+
+	channel.UpdatePeriod = s.cfg.FeedUpdatePeriod
+	if channel.UpdatePeriod == "" {
+		channel.UpdatePeriod = "daily"
+	}
+
+	channel.UpdateFrequency = 1
+	channel.TTL = updatePeriods[channel.UpdatePeriod]
+
+	docs := s.feedDocs()
+
+	if len(docs) > 0 {
+		channel.LastBuildDate = rss.Time(docs[0].Time)
+	}
+
+	for i, doc := range docs {
+		if i >= s.cfg.FeedArticles {
+			break
+		}
+
+		item := &rss.Item{
+			Title:       doc.Title,
+			Link:        canonical(doc),
+			GUID:        &rss.GUID{IsPermaLink: true, Value: canonical(doc)},
+			PubDate:     rss.Time(doc.Time),
+			Description: s.rssDescription(doc),
+		}
+
+		channel.Item = append(channel.Item, item)
+	}
+
+	data, err := xml.Marshal(&rss.Feed{Version: "2.0", Channel: channel})
+	if err != nil {
+		return err
+	}
+
+	s.rssFeed = data
+	return nil
+}
+
+var funcMap = template.FuncMap{
+	"canonical":   canonical,
+	"cspNonce":    cspNonce,
+	"paginate":    paginate,
+	"sectioned":   sectioned,
+	"authors":     authors,
+	"authorLinks": authorLinks,
+	"ToUpper":     strings.ToUpper,
+	"ToLower":     strings.ToLower,
+}
+
+// FuncMap: returns the template functions for the Server, combining the static
+// funcMap with helpers bound to the Server's articles.
+
+func (s *Server) funcMap() template.FuncMap {
+	funcs := template.FuncMap{
+		"moreInTag":       s.moreInTag,
+		"mathEnabled":     s.mathEnabled,
+		"mathJaxURL":      s.mathJaxURL,
+		"absURL":          s.absURL,
+		"baseURL":         func() string { return s.cfg.BaseURL },
+		"excerpt":         s.excerpt,
+		"readMore":        s.readMore,
+		"postsByYear":     s.postsByYear,
+		"metaDescription": s.metaDescription,
+		"feedLinks":       s.feedLinks,
+		"asset":           s.asset,
+		"fingerprint":     s.fingerprint,
+		"comments":        s.comments,
+		"editURL":         s.editURL,
+		"recentPosts":     s.recentPosts,
+		"postURL":         s.postURL,
+		"progressData":    progressData,
+		"popularPosts":    s.popularPosts,
+		"notes":           s.notes,
+		"isStale":         s.isStale,
+		"lang":            s.lang,
+		"dir":             s.dir,
+		"fmtDate":         s.fmtDate,
+		"fmtDateTime":     s.fmtDateTime,
+		"docStyles":       docStyles,
+		"docScripts":      docScripts,
+		"tagMeta":         s.tagMeta,
+		"tags":            func() []string { return s.tags },
+		"tagCounts":       s.tagCounts,
+		"svgSprite":       func() template.HTML { return s.svgSprite },
+		"announcement":    s.announcement,
+	}
+
+	for name, fn := range funcMap {
+		funcs[name] = fn
+	}
+
+	return funcs
+}
+
+// MoreInTag: returns up to limit of the most recent Docs (Articles) sharing the
+// tag, excluding the provided Doc (Article).
+
+func (s *Server) moreInTag(doc *Doc, tag string, limit int) []*Doc {
+	var docs []*Doc
+
+	for _, d := range s.docTags[tag] {
+		if len(docs) >= limit {
+			break
+		}
+
+		if d != doc {
+			docs = append(docs, d)
+		}
+	}
+
+	return docs
+}
+
+// AbsURL: returns the absolute URL for a path served by the Server. Paths
+// already under the BasePath, such as the Path of Docs, are kept; others are
+// mounted at it. The path is returned unchanged when no BaseURL is configured.
+
+func (s *Server) absURL(path string) string {
+	if s.cfg.BaseURL == "" {
+		return path
+	}
+
+	p := "/" + strings.TrimLeft(path, "/")
+
+	if s.cfg.BasePath != "" && (p == s.cfg.BasePath || strings.HasPrefix(p, s.cfg.BasePath+"/")) {
+		p = strings.TrimPrefix(p, s.cfg.BasePath)
+	}
+
+	return s.mountedURL(p)
+}
+
+// Excerpt: returns the summary of the Doc (Article) followed by a link to
+// continue reading, as used on listings and in the feeds.
+
+func (s *Server) excerpt(doc *Doc) template.HTML {
+	return doc.Summary + s.readMore(doc)
+}
+
+// ReadMore: returns a link continuing the Doc (Article) from the end of its summary.
+
+func (s *Server) readMore(doc *Doc) template.HTML {
+	text := s.cfg.ReadMoreText
+	if text == "" {
+		text = "Read more"
+	}
+
+	href := doc.Permalink
+	if doc.More {
+		href += "#" + moreID(doc.Source)
+	}
+
+	return template.HTML(fmt.Sprintf(`<a class="read-more" href="%s">%s</a>`,
+		template.HTMLEscapeString(href), template.HTMLEscapeString(text)))
+}
+
+// PostsByYear: returns the Docs (Articles) grouped by year, newest first.
+
+func (s *Server) postsByYear() []yearPosts {
+	var years []yearPosts
+
+	for _, doc := range s.docs {
+		year := doc.Time.In(s.location).Year() // Agrees with fmtDate.
+
+		if n := len(years); n == 0 || years[n-1].Year != year {
+			years = append(years, yearPosts{Year: year})
+		}
+
+		years[len(years)-1].Posts = append(years[len(years)-1].Posts, doc)
+	}
+
+	return years
+}
+
+// MetaDescription: returns the description of the page for a meta tag, given
+// either its Doc (Article) or the whole page data. An article is described by
+// its description metadata, or else its summary as plain text capped in length,
+// and a tag page by the description of its TagMeta. The SiteDescription is
+// returned otherwise, as on the homepage.
+
+func (s *Server) metaDescription(v interface{}) string {
+	switch v := v.(type) {
+	case *Doc:
+		if v != nil {
+			return docDescription(v)
+		}
+	case rootData:
+		if v.Doc != nil {
+			return docDescription(v.Doc)
+		}
+
+		if page, ok := v.Data.(tagPage); ok && page.Description != "" {
+			return page.Description
+		}
+	}
+
+	return s.cfg.SiteDescription
+}
+
+// DocDescription: returns the meta description of the Doc (Article).
+
+func docDescription(doc *Doc) string {
+	if doc.Description != "" {
+		return doc.Description
+	}
+
+	text := plainSummary(doc)
+
+	if r := []rune(text); len(r) > metaDescriptionLen {
+		text = string(r[:metaDescriptionLen])
+
+		if i := strings.LastIndex(text, " "); i > 0 {
+			text = text[:i]
+		}
+
+		text += "…"
+	}
+
+	return text
+}
+
+// FeedLinks: returns the enabled feeds, for advertising with <link rel="alternate">.
+
+func (s *Server) feedLinks() []feedLink {
+	var links []feedLink
+
+	if !s.cfg.DisableAtom {
+		links = append(links, feedLink{"application/atom+xml", s.cfg.FeedTitle, s.cfg.BasePath + "/feed.atom"})
+	}
+
+	if s.cfg.EnableRSS {
+		links = append(links, feedLink{"application/rss+xml", s.cfg.FeedTitle, s.cfg.BasePath + "/feed.rss"})
+	}
+
+	if s.cfg.EnableJSONFeed {
+		links = append(links, feedLink{"application/feed+json", s.cfg.FeedTitle, s.cfg.BasePath + "/feed.json"})
+	}
+
+	return links
+}
+
+// FeedAlias: returns the feed the path p, one of the FeedAliases, redirects to,
+// or "" for other paths or without feeds. The aliases "/atom" and "/rss" prefer
+// the feed in that format.
+
+func (s *Server) feedAlias(p string) string {
+	alias := false
+
+	for _, a := range s.cfg.FeedAliases {
+		alias = alias || a == p
+	}
+
+	links := s.feedLinks()
+	if !alias || len(links) == 0 {
+		return ""
+	}
+
+	for _, link := range links {
+		if link.Type == "application/"+strings.TrimPrefix(p, "/")+"+xml" {
+			return link.Href
+		}
+	}
+
+	return links[0].Href
+}
+
+// AssetPrefix: returns the path prefix of the theme assets.
+
+func (s *Server) assetPrefix() string {
+	if s.cfg.AssetPrefix != "" {
+		return s.cfg.AssetPrefix
+	}
+
+	return "/assets"
+}
+
+// Asset: returns the URL path of the named theme asset.
+
+func (s *Server) asset(name string) string {
+	return s.cfg.BasePath + s.assetPrefix() + "/" + strings.TrimLeft(name, "/")
+}
+
+// Fingerprint: returns the URL path of the named theme asset under its
+// content-hashed name, falling back to the plain asset URL for unknown names.
+
+func (s *Server) fingerprint(name string) string {
+	name = strings.TrimLeft(name, "/")
+
+	if s.assetFingerprints != nil {
+		if hashed, ok := s.assetFingerprints.hashed[name]; ok {
+			return s.asset(hashed)
+		}
+	}
+
+	return s.asset(name)
+}
+
+// Comments: returns the comment widget settings for the Doc (Article), or nil
+// when comments are disabled, for use as {{with comments .Doc}}.
+
+func (s *Server) comments(doc *Doc) *comments {
+	if !s.cfg.CommentsEnabled || doc == nil {
+		return nil
+	}
+
+	return &comments{
+		ScriptURL: s.cfg.CommentsScriptURL,
+		SiteID:    s.cfg.CommentsSiteID,
+		ThreadKey: doc.Path,
+		URL:       doc.Permalink,
+	}
+}
+
+// EditURL: returns the EditURLTemplate with the source file of the Doc (Article)
+// substituted, or "" when no template is set.
+
+func (s *Server) editURL(doc *Doc) string {
+	if s.cfg.EditURLTemplate == "" || doc == nil {
+		return ""
 	}
+
+	source := (&url.URL{Path: doc.Source}).EscapedPath()
+
+	return strings.ReplaceAll(s.cfg.EditURLTemplate, "{path}", source)
 }
 
-// LoadDocs: reads all articles for the provided file system root and renders all
-// the articles it finds.
+// Progress: the length of an article for a client-side reading-progress indicator.
 
-func (s *Server) loadDocs(root string) error {
-	// Read articles into docs (article) field.
-	const ext = ".article"
+type progress struct {
+	Words   int // Amount of words.
+	Chars   int // Amount of characters.
+	Minutes int // Estimated reading time, at least a minute.
+}
 
-	fn := func(p string, info os.FileInfo, err error) error {
-		if filepath.Ext(p) != ext {
-			return nil
-		}
+// ProgressData: returns the length of the Doc (Article) for a reading-progress
+// indicator, e.g. as data attributes of the article element. It is zero when
+// there is no Doc, as on the homepage.
 
-		f, err := os.Open(p)
+func progressData(doc *Doc) progress {
+	if doc == nil {
+		return progress{}
+	}
 
-		if err != nil {
-			return err
-		}
+	minutes := (doc.WordCount + wordsPerMinute - 1) / wordsPerMinute
+	if minutes < 1 {
+		minutes = 1
+	}
 
-		defer f.Close()
+	return progress{Words: doc.WordCount, Chars: doc.Chars, Minutes: minutes}
+}
 
-		d, err := present.Parse(f, p, 0)
+// PostURL: returns the URL path readers are pointed at for the Doc (Article),
+// its Path ending in a slash if TrailingSlash is set. Links in listings should
+// use it rather than the Path.
 
-		if err != nil {
-			return err
-		}
+func (s *Server) postURL(doc *Doc) string {
+	if s.cfg.TrailingSlash {
+		return doc.Path + "/"
+	}
 
-		html := new(bytes.Buffer)
+	return doc.Path
+}
 
-		err = d.Render(html, s.template.doc)
-		if err != nil {
-			return err
+// LooseDoc: returns the Doc (Article) that the path p (relative to the BasePath)
+// names if still percent-encoded or, with CaseInsensitiveURLs, in other letter
+// case, or nil.
+
+func (s *Server) looseDoc(p string) *Doc {
+	if strings.Contains(p, "%") {
+		if unescaped, err := url.PathUnescape(p); err == nil {
+			if doc, ok := s.docPaths[unescaped]; ok {
+				return doc
+			}
+
+			p = unescaped
 		}
+	}
 
-		p = p[len(root) : len(p)-len(ext)] // Trim root and extension.
-		p = filepath.ToSlash(p)
+	return s.docFolded[strings.ToLower(p)]
+}
 
-		log.Printf("%v", d)
+// SetAnnouncement: replaces the AnnouncementHTML and AnnouncementExpires of the
+// running Server, e.g. to post a notice without a restart. An empty html takes
+// the announcement down.
 
-		s.docs = append(s.docs, &Doc{
-			Doc:       d,
-			Intro:     d.Intro,
-			Image:     d.Image,
-			Category:  d.Category,
-			Path:      s.cfg.BasePath + p,
-			Permalink: s.cfg.BaseURL + p,
-			HTML:      template.HTML(html.String()),
-		})
+func (s *Server) SetAnnouncement(html template.HTML, expires time.Time) {
+	s.announcementMu.Lock()
+	defer s.announcementMu.Unlock()
 
-		return nil
-	}
+	s.announcementHTML, s.announcementExpires = html, expires
+}
 
-	err := filepath.Walk(root, fn)
-	if err != nil {
-		return err
+// Announcement: returns the current announcement, or "" once it has expired, for
+// use as {{with announcement}}.
+
+func (s *Server) announcement() template.HTML {
+	s.announcementMu.Lock()
+	defer s.announcementMu.Unlock()
+
+	if !s.announcementExpires.IsZero() && !time.Now().Before(s.announcementExpires) {
+		return ""
 	}
 
-	sort.Sort(docsByTime(s.docs))
+	return s.announcementHTML
+}
 
-	// Pull out doc (article) paths and tags and put in reverse-associating maps.
-	s.docPaths = make(map[string]*Doc)
-	s.docTags = make(map[string][]*Doc)
+// RecentPosts: returns the n most recent Docs (Articles) of the Server, on any
+// page and regardless of its data.
 
-	for _, d := range s.docs {
-		s.docPaths[strings.TrimPrefix(d.Path, s.cfg.BasePath)] = d
-		for _, t := range d.Tags {
-			s.docTags[t] = append(s.docTags[t], d)
-		}
+func (s *Server) recentPosts(n int) []*Doc {
+	if n > len(s.docs) {
+		n = len(s.docs)
 	}
 
-	// Pull out unique sorted list of tags.
-	for t := range s.docTags {
-		s.tags = append(s.tags, t)
+	if n < 0 {
+		n = 0
 	}
 
-	sort.Strings(s.tags)
+	return s.docs[:n]
+}
 
-	// Setup presentation-related fields, Newer, Older, and Related.
-	for _, doc := range s.docs {
-		// Newer, Older: docs adjacent to Doc (Article).
-		for i := range s.docs {
-			if s.docs[i] != doc {
-				continue
-			}
+// Notes: returns the presenter notes of the Doc (Article), or nil unless
+// ShowNotes is set so notes never leak into production builds.
 
-			if i > 0 {
-				doc.Newer = s.docs[i-1]
-			}
+func (s *Server) notes(doc *Doc) []string {
+	if !s.cfg.ShowNotes || doc == nil {
+		return nil
+	}
 
-			if i+1 < len(s.docs) {
-				doc.Older = s.docs[i+1]
-			}
+	return doc.Notes
+}
 
-			break
-		}
+// Lang: returns the language of the site for the lang attribute of the html element.
 
-		// Related: all docs (articles) that share tags with doc.
-		related := make(map[*Doc]bool)
+func (s *Server) lang() string {
+	if s.cfg.Language == "" {
+		return "en"
+	}
 
-		for _, t := range doc.Tags {
-			for _, d := range s.docTags[t] {
-				if d != doc {
-					related[d] = true
-				}
-			}
-		}
+	return s.cfg.Language
+}
 
-		for d := range related {
-			doc.Related = append(doc.Related, d)
-		}
+// Dir: returns the text direction of the site for the dir attribute of the html element.
 
-		sort.Sort(docsByTime(doc.Related))
+func (s *Server) dir() string {
+	if s.cfg.TextDirection == "" {
+		return "ltr"
 	}
 
-	return nil
+	return s.cfg.TextDirection
 }
 
-// RenderAtomFeed: generates an XML Atom feed and stores it in the Server's atomFeed field.
-
-func (s *Server) renderAtomFeed() error {
-	var updated time.Time
+// FmtDate: formats t with the DateFormat in the TimeZone, or returns "" for the
+// zero time.
 
-	if len(s.docs) > 0 {
-		updated = s.docs[0].Time
+func (s *Server) fmtDate(t time.Time) string {
+	layout := s.cfg.DateFormat
+	if layout == "" {
+		layout = "2 January 2006"
 	}
 
-	feed := atom.Feed{
-		Title:   s.cfg.FeedTitle,
-		ID:      "tag:" + s.cfg.Hostname + ",2013:" + s.cfg.Hostname,
-		Updated: atom.Time(updated),
-		Link: []atom.Link{{
-			Rel:  "self",
-			Href: s.cfg.BaseURL + "/feed.atom",
-		}},
+	return s.formatTime(t, layout)
+}
+
+// FmtDateTime: formats t with the DateTimeFormat in the TimeZone, or returns ""
+// for the zero time.
+
+func (s *Server) fmtDateTime(t time.Time) string {
+	layout := s.cfg.DateTimeFormat
+	if layout == "" {
+		layout = "2 January 2006 15:04"
 	}
 
-	for i, doc := range s.docs {
-		if i >= s.cfg.FeedArticles {
-			break
-		}
+	return s.formatTime(t, layout)
+}
 
-		e := &atom.Entry{
-			Title: doc.Title,
-			ID:    feed.ID + doc.Path,
-			Link: []atom.Link{{
-				Rel:  "alternative",
-				Href: doc.Permalink,
-			}},
-			Published: atom.Time(doc.Time),
-			Updated:   atom.Time(doc.Time),
-			Summary: &atom.Text{
-				Type: "html",
-				Body: summary(doc),
-			},
-			Content: &atom.Text{
-				Type: "html",
-				Body: string(doc.HTML),
-			},
-			Author: &atom.Person{
-				Name: authors(doc.Authors),
-			},
-		}
+// FormatTime: formats t with the layout in the TimeZone, or returns "" for the
+// zero time.
 
-		feed.Entry = append(feed.Entry, e)
+func (s *Server) formatTime(t time.Time, layout string) string {
+	if t.IsZero() {
+		return ""
 	}
 
-	data, err := xml.Marshal(&feed)
-	if err != nil {
-		return err
+	return t.In(s.location).Format(layout)
+}
+
+// IsStale: returns true if StaleAfter is set and the Doc (Article) is older than
+// it, so templates can warn that the article may be outdated.
+
+func (s *Server) isStale(doc *Doc) bool {
+	if s.cfg.StaleAfter <= 0 || doc == nil {
+		return false
 	}
 
-	s.atomFeed = data
-	return nil
+	return time.Since(doc.Time) > s.cfg.StaleAfter
 }
 
-// RenderJSONFeed: generates a JSON feed and stores it in the Server's jsonFeed field.
-
-func (s *Server) renderJSONFeed() error {
-	var feed []jsonItem
+// MathEnabled: returns true if math rendering is enabled and the Doc (Article)
+// contains math, so the script include is only emitted where it is needed.
 
-	for i, doc := range s.docs {
-		if i >= s.cfg.FeedArticles {
-			break
-		}
+func (s *Server) mathEnabled(doc *Doc) bool {
+	return s.cfg.MathJax && doc != nil && doc.Math
+}
 
-		item := jsonItem{
-			Title:   doc.Title,
-			Link:    doc.Permalink,
-			Time:    doc.Time,
-			Summary: summary(doc),
-			Content: string(doc.HTML),
-			Author:  authors(doc.Authors),
-		}
+// MathJaxURL: returns the configured MathJax script URL.
 
-		feed = append(feed, item)
+func (s *Server) mathJaxURL() string {
+	if s.cfg.MathJaxURL != "" {
+		return s.cfg.MathJaxURL
 	}
 
-	data, err := json.Marshal(feed)
+	return defaultMathJaxURL
+}
 
-	if err != nil {
-		return err
+// Canonical: returns the canonical URL of the Doc (Article): its canonical
+// metadata when syndicated from elsewhere, otherwise its Permalink.
+
+func canonical(doc *Doc) string {
+	if doc.Canonical != "" {
+		return doc.Canonical
 	}
 
-	s.jsonFeed = data
-	return nil
+	return doc.Permalink
 }
 
-var funcMap = template.FuncMap{
-	"sectioned": sectioned,
-	"authors":   authors,
-	"ToUpper":   strings.ToUpper,
-	"ToLower":   strings.ToLower,
+// CspNonce: returns the content security policy nonce of the page, for use as
+// {{cspNonce $}} in the nonce attribute of inline scripts.
+
+func cspNonce(d rootData) string {
+	return d.Nonce
+}
+
+// Paginate: returns the links of a windowed pagination control such as
+// "1 … 4 5 6 … 20" for page current of total, linking to base?page=N as
+// parsed by ServeHTTP.
+
+func paginate(current, total int, base string) []pageLink {
+	var links []pageLink
+
+	for n := 1; n <= total; n++ {
+		if n != 1 && n != total && (n < current-1 || n > current+1) {
+			if len(links) > 0 && !links[len(links)-1].Gap {
+				links = append(links, pageLink{Gap: true})
+			}
+			continue
+		}
+
+		url := base
+		if n > 1 {
+			url += "?page=" + strconv.Itoa(n)
+		}
+
+		links = append(links, pageLink{Number: n, URL: url, Current: n == current})
+	}
+
+	return links
 }
 
 // Sectioned: returns true if the Doc (Article) contains more than one section.
@@ -460,9 +3206,191 @@ func authorName(a present.Author) string {
 	return text.Lines[0]
 }
 
-// Summary: returns the first paragraph of text from the provided Doc (Article).
+// AuthorURL: returns the first web link of the Author, e.g. a homepage, or ""
+// if there is none.
+
+func authorURL(a present.Author) string {
+	for _, el := range a.Elem {
+		if link, ok := el.(present.Link); ok && (link.URL.Scheme == "http" || link.URL.Scheme == "https") {
+			return link.URL.String()
+		}
+	}
+
+	return ""
+}
+
+// SoleAuthorURL: returns the URL of the author when there is exactly one, since
+// the feeds name several authors together.
+
+func soleAuthorURL(authors []present.Author) string {
+	if len(authors) != 1 {
+		return ""
+	}
+
+	return authorURL(authors[0])
+}
+
+// AuthorLink: an author of a byline with the URL of their homepage, if any.
+
+type authorLink struct {
+	Name string
+	URL  string
+}
+
+// AuthorLinks: returns the names and URLs of the authors for bylines.
+
+func authorLinks(authors []present.Author) []authorLink {
+	links := make([]authorLink, 0, len(authors))
+
+	for _, a := range authors {
+		links = append(links, authorLink{Name: authorName(a), URL: authorURL(a)})
+	}
+
+	return links
+}
+
+// DocNotes: returns the presenter notes of the provided Doc, those of its title
+// first and then those of its sections and subsections in order.
+
+func docNotes(d *present.Doc) []string {
+	notes := append([]string(nil), d.TitleNotes...)
+
+	var walk func(sections []present.Section)
+
+	walk = func(sections []present.Section) {
+		for _, section := range sections {
+			notes = append(notes, section.Notes...)
+
+			var subsections []present.Section
+
+			for _, elem := range section.Elem {
+				if ss, ok := elem.(present.Section); ok {
+					subsections = append(subsections, ss)
+				}
+			}
+
+			walk(subsections)
+		}
+	}
+
+	walk(d.Sections)
+
+	return notes
+}
+
+// SnippetText: returns the preformatted text and code of the sections and their
+// subsections as plain text.
+
+func snippetText(sections []present.Section) string {
+	var b strings.Builder
+
+	for _, section := range sections {
+		for _, elem := range section.Elem {
+			switch e := elem.(type) {
+			case present.Text:
+				if e.Pre {
+					b.WriteString(strings.Join(e.Lines, "\n"))
+					b.WriteByte('\n')
+				}
+			case present.Code:
+				b.WriteString(stripTags(string(e.Text)))
+			case present.Section:
+				b.WriteString(snippetText([]present.Section{e}))
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// MetadataList: returns the comma-separated values of the metadata key of the
+// provided Doc, with surrounding spaces and empty values removed.
+
+func metadataList(d *present.Doc, key string) []string {
+	var values []string
+
+	for _, v := range strings.Split(d.Metadata[key], ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
+
+// IncludableURL: reports whether the URL of a stylesheet or script is absolute
+// (http or https) or rooted at the site.
+
+func includableURL(s string) bool {
+	if strings.HasPrefix(s, "/") && !strings.HasPrefix(s, "//") {
+		return true
+	}
+
+	u, err := url.Parse(s)
+
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// DocStyles: returns the stylesheet URLs needed by the Doc (Article).
+
+func docStyles(doc *Doc) []string {
+	if doc == nil {
+		return nil
+	}
+
+	return doc.Styles
+}
+
+// DocScripts: returns the script URLs needed by the Doc (Article).
+
+func docScripts(doc *Doc) []string {
+	if doc == nil {
+		return nil
+	}
+
+	return doc.Scripts
+}
+
+// MetadataBool: reports whether the Doc's (Article's) metadata key holds a true
+// value such as "true", "yes" or "1".
 
-func summary(d *Doc) string {
+func metadataBool(d *present.Doc, key string) bool {
+	v := strings.ToLower(d.Metadata[key])
+	if v == "yes" {
+		return true
+	}
+
+	b, _ := strconv.ParseBool(v)
+	return b
+}
+
+// Summary: returns the summary of the provided Doc (Article) following the
+// configured SummaryStrategy, preferring its summary metadata when present.
+// Summaries are built from styled text alone, so they never hold images.
+
+func (s *Server) summary(d *present.Doc) template.HTML {
+	if text := d.Metadata["summary"]; text != "" {
+		return present.Style(text)
+	}
+
+	switch s.cfg.SummaryStrategy {
+	case "explicit":
+		return ""
+	case "first-n-words":
+		n := s.cfg.SummaryWords
+		if n == 0 {
+			n = defaultSummaryWords
+		}
+
+		return firstWords(d, n)
+	}
+
+	return firstParagraph(d)
+}
+
+// FirstParagraph: returns the first paragraph of text from the provided Doc (Article).
+
+func firstParagraph(d *present.Doc) template.HTML {
 	if len(d.Sections) == 0 {
 		return ""
 	}
@@ -480,13 +3408,41 @@ func summary(d *Doc) string {
 			buf.WriteByte('\n')
 		}
 
-		return buf.String()
+		return template.HTML(buf.String())
 	}
 
 	return ""
 }
 
-// DocsByTime implements sort.Interface, sorting Docs by their Time field.
+// FirstWords: returns the first n words of the text of the provided Doc
+// (Article) as plain text, followed by an ellipsis when it was cut short.
+
+func firstWords(d *present.Doc, n int) template.HTML {
+	var words []string
+
+	for _, section := range d.Sections {
+		for _, elem := range section.Elem {
+			text, ok := elem.(present.Text)
+			if !ok || text.Pre {
+				continue
+			}
+
+			for _, line := range text.Lines {
+				words = append(words, strings.Fields(stripTags(string(present.Style(line))))...)
+			}
+
+			if len(words) > n {
+				return template.HTML(template.HTMLEscapeString(strings.Join(words[:n], " ")) + "…")
+			}
+		}
+	}
+
+	return template.HTML(template.HTMLEscapeString(strings.Join(words, " ")))
+}
+
+// DocsByTime implements sort.Interface, sorting Docs by their Time field, newest
+// first. Docs sharing a Time are ordered by Path and then Title so the order is
+// stable across loads.
 
 type docsByTime []*Doc
 
@@ -499,5 +3455,13 @@ func (s docsByTime) Swap(i, j int) {
 }
 
 func (s docsByTime) Less(i, j int) bool {
-	return s[i].Time.After(s[j].Time)
+	if !s[i].Time.Equal(s[j].Time) {
+		return s[i].Time.After(s[j].Time)
+	}
+
+	if s[i].Path != s[j].Path {
+		return s[i].Path < s[j].Path
+	}
+
+	return s[i].Title < s[j].Title
 }
@@ -0,0 +1,560 @@
+package blog
+
+import (
+	"bytes"
+	"encoding/xml"
+	"html/template"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ryank90/utilities/blog/atom"
+	"github.com/ryank90/utilities/present"
+)
+
+// testServer: returns a Server holding a single article at /hello, served
+// under the given BasePath.
+
+func testServer(basePath string) *Server {
+	cfg := Config{
+		BaseURL:      "https://example.com",
+		BasePath:     basePath,
+		Hostname:     "example.com",
+		FeedArticles: 10,
+	}
+
+	doc := &Doc{
+		Doc: &present.Doc{
+			Title: "Hello",
+			Time:  time.Date(2013, 1, 2, 11, 0, 0, 0, time.UTC),
+		},
+		Path:      basePath + "/hello",
+		Permalink: cfg.BaseURL + basePath + "/hello",
+	}
+
+	return &Server{cfg: cfg, loaded: loaded{docs: []*Doc{doc}}}
+}
+
+// LoadTestServer: returns a Server with cfg that loaded the given article files,
+// keyed by their path in the content directory.
+
+func loadTestServer(t *testing.T, cfg Config, files map[string]string) *Server {
+	t.Helper()
+
+	cfg.setDefaults()
+
+	content := make(fstest.MapFS)
+	for name, text := range files {
+		content[name] = &fstest.MapFile{Data: []byte(text)}
+	}
+
+	s := &Server{cfg: cfg, contentFS: content}
+
+	// Articles render as the titles and paragraphs of their sections.
+	var err error
+
+	s.template.doc, err = present.Template().Parse(`{{define "root"}}{{range .Sections}}<h2>{{.Title}}</h2>` +
+		`{{range .Elem}}{{elem $.Template .}}{{end}}{{end}}{{end}}` +
+		`{{define "text"}}<p>{{range .Lines}}{{style .}}{{end}}</p>{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.loadDocs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+// TestArticle: returns the text of an article with the title, date and header
+// lines.
+
+func testArticle(title, date string, header ...string) string {
+	text := title + "\n" + date + "\n"
+	for _, line := range header {
+		text += line + "\n"
+	}
+
+	return text + "\nAuthor\n\n* Section\n\nText of " + title + ".\n"
+}
+
+func TestAtomEntryIDIgnoresBasePath(t *testing.T) {
+	want := testServer("").atomFeedFor(testServer("").docs, "").Entry[0].ID
+
+	for _, basePath := range []string{"", "/blog", "/a/b"} {
+		s := testServer(basePath)
+		feed := s.atomFeedFor(s.docs, "")
+
+		if got := feed.Entry[0].ID; got != want {
+			t.Errorf("BasePath %q: entry ID = %q, want %q", basePath, got, want)
+		}
+	}
+
+	if want != "tag:example.com,2013:example.com/hello" {
+		t.Errorf("entry ID = %q, want derived from the permalink", want)
+	}
+}
+
+func TestDocsByTimeBreaksTies(t *testing.T) {
+	at := time.Date(2013, 1, 2, 11, 0, 0, 0, time.UTC)
+	doc := func(path, title string, t time.Time) *Doc {
+		return &Doc{Doc: &present.Doc{Title: title, Time: t}, Path: path}
+	}
+
+	docs := []*Doc{
+		doc("/b", "B", at),
+		doc("/a", "Z", at),
+		doc("/old", "Old", at.Add(-time.Hour)),
+		doc("/a", "A", at),
+		doc("/new", "New", at.Add(time.Hour)),
+	}
+
+	sort.Sort(docsByTime(docs))
+
+	var got []string
+	for _, d := range docs {
+		got = append(got, d.Title)
+	}
+
+	want := []string{"New", "A", "Z", "B", "Old"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("order = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestAtomSelfLinkIncludesBasePath(t *testing.T) {
+	for basePath, want := range map[string]string{
+		"":      "https://example.com/feed.atom",
+		"/blog": "https://example.com/blog/feed.atom",
+	} {
+		s := testServer(basePath)
+
+		err := s.renderAtomFeed()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var feed atom.Feed
+
+		err = xml.Unmarshal(s.atomFeed, &feed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var self string
+		for _, l := range feed.Link {
+			if l.Rel == "self" {
+				self = l.Href
+			}
+		}
+
+		if self != want {
+			t.Errorf("BasePath %q: self link = %q, want %q", basePath, self, want)
+		}
+	}
+}
+
+func TestSortOrderKeepsListingsByDate(t *testing.T) {
+	s := loadTestServer(t, Config{SortOrder: "order-field"}, map[string]string{
+		"a.article": testArticle("A", "1 Jan 2013", "order: 2"),
+		"b.article": testArticle("B", "2 Jan 2013", "order: 1"),
+		"c.article": testArticle("C", "3 Jan 2013"),
+	})
+
+	var listed []string
+	for _, doc := range s.docs {
+		listed = append(listed, doc.Title)
+	}
+
+	if got := strings.Join(listed, ","); got != "C,B,A" {
+		t.Errorf("listing = %s, want newest first C,B,A", got)
+	}
+
+	b := s.docPaths["/b"]
+	if b.Newer != nil || b.Older == nil || b.Older.Title != "A" {
+		t.Errorf("B: Newer = %v, Older = %v, want none and A", b.Newer, b.Older)
+	}
+
+	if a := s.docPaths["/a"]; a.Older == nil || a.Older.Title != "C" {
+		t.Errorf("A: Older = %v, want C", a.Older)
+	}
+}
+
+func TestAuthorFeedsHaveOwnIDs(t *testing.T) {
+	s := loadTestServer(t, Config{Hostname: "example.com"}, map[string]string{
+		"a.article": testArticle("A", "1 Jan 2013"),
+	})
+
+	err := s.renderAuthorFeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var feed atom.Feed
+
+	err = xml.Unmarshal(s.authorFeeds["author"], &feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "tag:example.com,2013:example.com:author/author"; feed.ID != want {
+		t.Errorf("author feed ID = %q, want %q", feed.ID, want)
+	}
+}
+
+func TestAbsoluteURLsIncludeBasePath(t *testing.T) {
+	s := loadTestServer(t, Config{BaseURL: "https://example.com", BasePath: "/blog"}, map[string]string{
+		"a.article": testArticle("A", "1 Jan 2013"),
+	})
+
+	if got, want := s.docPaths["/a"].Permalink, "https://example.com/blog/a"; got != want {
+		t.Errorf("Permalink = %q, want %q", got, want)
+	}
+
+	for path, want := range map[string]string{
+		"/feed.atom": "https://example.com/blog/feed.atom",
+		"/blog/a":    "https://example.com/blog/a",
+		"images/x":   "https://example.com/blog/images/x",
+	} {
+		if got := s.absURL(path); got != want {
+			t.Errorf("absURL(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestTrailingSlashPermalinks(t *testing.T) {
+	s := loadTestServer(t, Config{BaseURL: "https://example.com", TrailingSlash: true}, map[string]string{
+		"a.article": testArticle("A", "1 Jan 2013"),
+	})
+
+	doc := s.docPaths["/a"]
+
+	if got, want := canonical(doc), "https://example.com/a/"; got != want {
+		t.Errorf("canonical = %q, want %q", got, want)
+	}
+
+	if got, want := s.postURL(doc), "/a/"; got != want {
+		t.Errorf("postURL = %q, want %q", got, want)
+	}
+
+	if got, want := s.atomFeedFor(s.docs, "").Entry[0].ID, "tag:,2013:/a"; got != want {
+		t.Errorf("entry ID = %q, want %q", got, want)
+	}
+}
+
+func TestSitemapListsIndexedArticles(t *testing.T) {
+	s := loadTestServer(t, Config{BaseURL: "https://example.com", BasePath: "/blog"}, map[string]string{
+		"a.article": testArticle("A", "1 Jan 2013"),
+		"b.article": testArticle("B", "2 Jan 2013", "noindex: true"),
+	})
+
+	err := s.renderSitemap()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var set sitemapURLSet
+
+	err = xml.Unmarshal(s.sitemap, &set)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, u := range set.URL {
+		got = append(got, u.Loc+" "+u.LastMod)
+	}
+
+	want := "https://example.com/blog/ 2013-01-01,https://example.com/blog/a 2013-01-01"
+	if strings.Join(got, ",") != want {
+		t.Errorf("sitemap = %v, want %s", got, want)
+	}
+}
+
+func TestMoreAnchorEndsSummary(t *testing.T) {
+	s := loadTestServer(t, Config{BaseURL: "https://example.com"}, map[string]string{
+		"a.article": testArticle("A", "1 Jan 2013") + "\nMore of A.\n",
+		"b.article": testArticle("B", "2 Jan 2013"),
+		"c.article": testArticle("C", "3 Jan 2013", "summary: Told elsewhere.") + "\nMore of C.\n",
+	})
+
+	a := s.docPaths["/a"]
+	if want := `<p>Text of A.<span id="more-a"></span></p><p>More of A.</p>`; !strings.Contains(string(a.HTML), want) {
+		t.Errorf("A: HTML = %s, want the anchor after the summary", a.HTML)
+	}
+
+	if got, want := s.readMore(a), `href="https://example.com/a#more-a"`; !strings.Contains(string(got), want) {
+		t.Errorf("A: readMore = %s, want %s", got, want)
+	}
+
+	for _, p := range []string{"/b", "/c"} {
+		doc := s.docPaths[p]
+		if doc.More || strings.Contains(string(doc.HTML), "more-") || strings.Contains(string(s.readMore(doc)), "#") {
+			t.Errorf("%s: HTML = %s, readMore = %s, want no anchor", p, doc.HTML, s.readMore(doc))
+		}
+	}
+}
+
+func TestRecommendedNext(t *testing.T) {
+	s := loadTestServer(t, Config{}, map[string]string{
+		"Intro_Part.article":  testArticle("Intro", "1 Jan 2013", "next: Second Part"),
+		"second-part.article": testArticle("Second", "2 Jan 2013", "next: missing"),
+	})
+
+	if next := s.docPaths["/Intro_Part"].RecommendedNext; next == nil || next.Title != "Second" {
+		t.Errorf("Intro: RecommendedNext = %v, want Second", next)
+	}
+
+	warnings := s.report.Warnings
+	if len(warnings) != 1 || warnings[0].File != "second-part.article" {
+		t.Errorf("warnings = %v, want the missing next article of second-part.article", warnings)
+	}
+}
+
+func TestFeedLinksFollowToggles(t *testing.T) {
+	for _, tt := range []struct {
+		cfg  Config
+		want string
+	}{
+		{Config{}, "/feed.atom"},
+		{Config{DisableAtom: true, EnableRSS: true, EnableJSONFeed: true}, "/feed.rss,/feed.json"},
+	} {
+		s := &Server{cfg: tt.cfg}
+
+		var got []string
+		for _, l := range s.feedLinks() {
+			got = append(got, l.Href)
+		}
+
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("%+v: feedLinks = %v, want %s", tt.cfg, got, tt.want)
+		}
+	}
+}
+
+func TestMetaDescriptionOfTagPages(t *testing.T) {
+	s := &Server{cfg: Config{
+		SiteDescription: "Site",
+		TagMeta:         map[string]TagMeta{"go": {Description: "All about Go."}},
+	}}
+
+	for _, tt := range []struct {
+		data interface{}
+		want string
+	}{
+		{rootData{Data: s.tagPage("go")}, "All about Go."},
+		{rootData{Data: s.tagPage("rust")}, "Site"},
+		{(*Doc)(nil), "Site"},
+	} {
+		if got := s.metaDescription(tt.data); got != tt.want {
+			t.Errorf("metaDescription(%v) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestDateFromFilename(t *testing.T) {
+	undated := "Title\n\nAuthor\n\n* Section\n\nText.\n"
+
+	s := loadTestServer(t, Config{DateFromFilename: "2006-01-02-slug"}, map[string]string{
+		"2013-01-02-dated.article": undated,
+		"undated.article":          undated,
+	})
+
+	if got, want := s.docPaths["/2013-01-02-dated"].Time, time.Date(2013, 1, 2, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Time = %v, want %v", got, want)
+	}
+
+	for _, when := range []string{"load", "reload"} {
+		warnings := s.report.Warnings
+		if len(warnings) != 1 || warnings[0].File != "undated.article" {
+			t.Errorf("%s: warnings = %v, want the undated.article name", when, warnings)
+		}
+
+		_, err := s.loadDocs()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSetAnnouncementBypassesCache(t *testing.T) {
+	s := &Server{cfg: Config{CachePages: true}}
+	s.storeAllPage([]byte("all"))
+
+	s.SetAnnouncement("Down for maintenance.", time.Now().Add(time.Hour))
+
+	if got := s.announcement(); got != "Down for maintenance." {
+		t.Errorf("announcement = %q, want the notice", got)
+	}
+
+	if s.cachePages() || s.cachedAllPage() != nil {
+		t.Error("cache used while the announcement shows")
+	}
+
+	s.SetAnnouncement("Over.", time.Now().Add(-time.Hour))
+
+	if got := s.announcement(); got != "" {
+		t.Errorf("announcement = %q after expiry, want none", got)
+	}
+
+	if !s.cachePages() || s.cachedAllPage() == nil {
+		t.Error("cache not used after the announcement expired")
+	}
+}
+
+func TestCaseInsensitiveURLsPreferNewest(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		s := loadTestServer(t, Config{CaseInsensitiveURLs: true}, map[string]string{
+			"Post.article": testArticle("Old", "1 Jan 2013"),
+			"post.article": testArticle("New", "2 Jan 2013"),
+		})
+
+		if doc := s.docFolded["/post"]; doc == nil || doc.Title != "New" {
+			t.Fatalf("folded /post = %v, want New", doc)
+		}
+
+		warnings := s.report.Warnings
+		if len(warnings) != 1 || warnings[0].File != "Post.article" {
+			t.Fatalf("warnings = %v, want the collision of Post.article", warnings)
+		}
+	}
+}
+
+func TestFailedLoadKeepsServedState(t *testing.T) {
+	content := fstest.MapFS{
+		"a.article": {Data: []byte(testArticle("A", "1 Jan 2013"))},
+		"b.article": {Data: []byte(testArticle("B", "2 Jan 2013"))},
+	}
+
+	s := loadTestServer(t, Config{SlugCollision: "error"}, nil)
+	s.contentFS = content
+
+	_, err := s.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, feed := s.docPaths["/a"], s.atomFeed
+
+	// B turns into a second /a, so the slugs of the unchanged A collide.
+	content["b.article"] = &fstest.MapFile{Data: []byte(testArticle("B", "2 Jan 2013", "slug: a"))}
+
+	_, err = s.load()
+	if err == nil {
+		t.Fatal("load succeeded despite the slug collision")
+	}
+
+	if s.docPaths["/a"] != a || len(s.docs) != 2 || string(s.atomFeed) != string(feed) {
+		t.Error("failed load changed the served articles or feeds")
+	}
+
+	if a.Path != "/a" || a.Newer == nil || a.Newer.Title != "B" {
+		t.Errorf("served A: Path = %q, Newer = %v, want /a and B", a.Path, a.Newer)
+	}
+}
+
+func TestSummaryHoldsNoImages(t *testing.T) {
+	s := loadTestServer(t, Config{}, map[string]string{
+		"a.article": "A\n1 Jan 2013\n\nAuthor\n\n* Section\n\nText <img src=\"data:image/png;base64,AAAA\">.\n",
+	})
+
+	if summary := string(s.docPaths["/a"].Summary); strings.Contains(summary, "<img") {
+		t.Errorf("Summary = %s, want the markup escaped", summary)
+	}
+}
+
+func TestWarmKeepsRobots(t *testing.T) {
+	s := loadTestServer(t, Config{CachePages: true}, map[string]string{
+		"a.article": testArticle("A", "1 Jan 2013", "noindex: true"),
+	})
+
+	var err error
+
+	s.template.article, err = template.New("").Parse(`{{define "root"}}{{with .Robots}}<meta name="robots" content="{{.}}">{{end}}{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Warm()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rendered bytes.Buffer
+
+	err = s.RenderDoc(&rendered, "/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<meta name="robots" content="noindex">`
+	if page, _ := s.cachedPage("/a"); string(page) != want || rendered.String() != want {
+		t.Errorf("warmed %q, rendered %q, want %q", page, rendered.String(), want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := DefaultConfig().Validate(); err == nil || !strings.Contains(err.Error(), "ArticlePath") {
+		t.Errorf("DefaultConfig without paths: error = %v, want one naming ArticlePath", err)
+	}
+
+	valid := DefaultConfig()
+	valid.ArticlePath, valid.ThemePath = "content", "theme"
+
+	if err := valid.Validate(); err != nil {
+		t.Errorf("DefaultConfig: %v", err)
+	}
+
+	invalid := valid
+	invalid.FeedSummaryType = "txt"
+
+	if err := invalid.Validate(); err == nil || !strings.Contains(err.Error(), "FeedSummaryType") {
+		t.Errorf("FeedSummaryType \"txt\": error = %v, want one naming the field", err)
+	}
+}
+
+func TestProgressData(t *testing.T) {
+	if got := progressData(nil); got != (progress{}) {
+		t.Errorf("progressData(nil) = %+v, want zero", got)
+	}
+
+	if got := progressData(&Doc{WordCount: 450}); got.Minutes != 3 {
+		t.Errorf("Minutes = %d, want 3", got.Minutes)
+	}
+}
+
+func TestAtomArchivesAreNotComplete(t *testing.T) {
+	s := loadTestServer(t, Config{AtomArticles: 1}, map[string]string{
+		"a.article": testArticle("A", "1 Jan 2013"),
+		"b.article": testArticle("B", "2 Jan 2013"),
+		"c.article": testArticle("C", "3 Jan 2013"),
+	})
+
+	err := s.renderAtomFeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.atomArchive) != 2 {
+		t.Fatalf("archive documents = %d, want 2", len(s.atomArchive))
+	}
+
+	for i, data := range s.atomArchive {
+		var feed atom.Feed
+
+		err := xml.Unmarshal(data, &feed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if feed.Archive == nil || feed.Complete != nil {
+			t.Errorf("archive %d: archive = %v, complete = %v, want only archive", i+1, feed.Archive != nil, feed.Complete != nil)
+		}
+	}
+}
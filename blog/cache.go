@@ -0,0 +1,100 @@
+package blog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CachePages: reports whether rendered article pages are cached. Pages are
+// never cached with a ContentSecurityPolicy since each carries a fresh nonce,
+// nor served from the cache while an announcement shows.
+
+func (s *Server) cachePages() bool {
+	return s.cfg.CachePages && s.cfg.ContentSecurityPolicy == "" && s.announcement() == ""
+}
+
+// CachedPage: returns the cached page of the article at path (relative to the
+// BasePath).
+
+func (s *Server) cachedPage(path string) ([]byte, bool) {
+	s.pagesMu.Lock()
+	defer s.pagesMu.Unlock()
+
+	page, ok := s.pages[path]
+
+	return page, ok
+}
+
+// StorePage: caches the page of the article at path (relative to the BasePath).
+
+func (s *Server) storePage(path string, page []byte) {
+	s.pagesMu.Lock()
+	defer s.pagesMu.Unlock()
+
+	if s.pages == nil {
+		s.pages = make(map[string][]byte)
+	}
+
+	s.pages[path] = page
+}
+
+// CachedAllPage: returns the cached /all page, or nil. The page is not cached
+// with a ContentSecurityPolicy since it carries a fresh nonce, nor served from
+// the cache while an announcement shows.
+
+func (s *Server) cachedAllPage() []byte {
+	if s.cfg.ContentSecurityPolicy != "" || s.announcement() != "" {
+		return nil
+	}
+
+	s.pagesMu.Lock()
+	defer s.pagesMu.Unlock()
+
+	return s.allPageHTML
+}
+
+// StoreAllPage: caches the /all page.
+
+func (s *Server) storeAllPage(page []byte) {
+	if s.cfg.ContentSecurityPolicy != "" {
+		return
+	}
+
+	s.pagesMu.Lock()
+	defer s.pagesMu.Unlock()
+
+	s.allPageHTML = page
+}
+
+// Warm: renders every article into the page cache so that no request has to
+// wait for a cold page. It does nothing unless CachePages is set, and reports
+// the articles that failed to render together.
+
+func (s *Server) Warm() error {
+	if !s.cachePages() {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var errs []error
+
+	for _, doc := range s.docs {
+		path := strings.TrimPrefix(doc.Path, s.cfg.BasePath)
+
+		var b bytes.Buffer
+
+		err := s.template.article.ExecuteTemplate(&b, "root", s.articleData(doc))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("blog: warm %s: %w", path, err))
+			continue
+		}
+
+		s.storePage(path, b.Bytes())
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,69 @@
+package blog
+
+import (
+	"sync"
+	"time"
+)
+
+// Buffered events per subscriber; further events are dropped until it catches up.
+
+const reloadEventBuffer = 4
+
+// ReloadEvent: describes a successful Reload.
+
+type ReloadEvent struct {
+	Changed []string  // Paths of the articles added, updated or removed.
+	Time    time.Time // When the reload finished.
+}
+
+// ReloadEvents: returns a channel receiving an event after every successful
+// Reload, and a function cancelling the subscription, which closes the channel.
+// Each call subscribes a new channel. Events are never waited for: a subscriber
+// whose buffer is full misses them rather than stalling reloads.
+
+func (s *Server) ReloadEvents() (<-chan ReloadEvent, func()) {
+	ch := make(chan ReloadEvent, reloadEventBuffer)
+
+	s.subsMu.Lock()
+	s.subs = append(s.subs, ch)
+	s.subsMu.Unlock()
+
+	var once sync.Once
+
+	cancel := func() {
+		once.Do(func() {
+			s.subsMu.Lock()
+			defer s.subsMu.Unlock()
+
+			for i, sub := range s.subs {
+				if sub == ch {
+					s.subs = append(s.subs[:i], s.subs[i+1:]...)
+					break
+				}
+			}
+
+			close(ch)
+		})
+	}
+
+	return ch, cancel
+}
+
+// PublishReload: sends a ReloadEvent to every subscriber with room for it, each
+// with its own copy of changed.
+
+func (s *Server) publishReload(changed []string) {
+	now := time.Now()
+
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+
+	for _, ch := range s.subs {
+		event := ReloadEvent{Changed: append([]string(nil), changed...), Time: now}
+
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
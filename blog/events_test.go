@@ -0,0 +1,33 @@
+package blog
+
+import "testing"
+
+func TestReloadEventsCancel(t *testing.T) {
+	s := testServer("")
+
+	events, cancel := s.ReloadEvents()
+	other, cancelOther := s.ReloadEvents()
+	defer cancelOther()
+
+	s.publishReload([]string{"/hello"})
+
+	event := <-events
+	event.Changed[0] = "/mutated"
+
+	if got := (<-other).Changed[0]; got != "/hello" {
+		t.Errorf("other subscriber got %q, want its own copy of /hello", got)
+	}
+
+	cancel()
+	cancel()
+
+	if _, ok := <-events; ok {
+		t.Error("channel still open after cancel")
+	}
+
+	if len(s.subs) != 1 {
+		t.Errorf("%d subscribers after cancel, want 1", len(s.subs))
+	}
+
+	s.publishReload(nil) // Must not send on the closed channel.
+}
@@ -0,0 +1,158 @@
+package blog
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Handler: returns an http.Handler serving the Server's articles and feeds,
+// wrapped with the request guards specified by the configuration. Prefer it to
+// using the Server directly.
+
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.MaxRequestBody > 0 && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			r.Body = http.MaxBytesReader(w, r.Body, s.cfg.MaxRequestBody)
+		}
+
+		start := time.Now()
+
+		if s.cfg.SlowRequestThreshold > 0 {
+			defer func() {
+				if d := time.Since(start); d > s.cfg.SlowRequestThreshold {
+					log.Printf("blog: warning: slow request %s %s took %v", r.Method, r.URL.Path, d)
+				}
+			}()
+		}
+
+		if s.cfg.AccessLog != nil {
+			sw := &statusWriter{ResponseWriter: w}
+			w = sw
+
+			defer func() {
+				s.logAccess(r, sw, start)
+			}()
+		}
+
+		scheme, host := s.origin(r)
+
+		if s.cfg.ForceHTTPS && scheme != "https" {
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+			return
+		}
+
+		if s.cfg.TrustProxyHeaders {
+			r = r.Clone(r.Context())
+			r.Host, r.URL.Host, r.URL.Scheme = host, host, scheme
+		}
+
+		s.ServeHTTP(w, r)
+	})
+}
+
+// Origin: returns the scheme and host the client used for the request, taken
+// from the X-Forwarded-Proto and X-Forwarded-Host headers only when
+// TrustProxyHeaders is set since clients can forge them.
+
+func (s *Server) origin(r *http.Request) (scheme, host string) {
+	scheme, host = "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	if !s.cfg.TrustProxyHeaders {
+		return scheme, host
+	}
+
+	if proto := lastForwarded(r.Header, "X-Forwarded-Proto"); proto != "" {
+		scheme = strings.ToLower(proto)
+	}
+
+	if fwd := lastForwarded(r.Header, "X-Forwarded-Host"); fwd != "" {
+		host = fwd
+	}
+
+	return scheme, host
+}
+
+// LastForwarded: returns the last value of the comma-separated header. Proxies
+// append to X-Forwarded-* headers, so only the last value was set by the proxy
+// in front of the server; earlier ones may come from the client.
+
+func lastForwarded(h http.Header, key string) string {
+	values := h.Values(key)
+	if len(values) == 0 {
+		return ""
+	}
+
+	last := values[len(values)-1]
+	if i := strings.LastIndex(last, ","); i >= 0 {
+		last = last[i+1:]
+	}
+
+	return strings.TrimSpace(last)
+}
+
+// StatusWriter: records the status and the amount of bytes of a response.
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+
+	return n, err
+}
+
+// LogAccess: writes a line in the Apache combined log format, followed by the
+// duration of the request, to the AccessLog.
+
+func (s *Server) logAccess(r *http.Request, w *statusWriter, start time.Time) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	size := "-"
+	if w.bytes > 0 {
+		size = fmt.Sprint(w.bytes)
+	}
+
+	line := fmt.Sprintf("%s - - [%s] %q %d %s %q %q %v\n",
+		host, start.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method+" "+r.RequestURI+" "+r.Proto, status, size,
+		r.Referer(), r.UserAgent(), time.Since(start))
+
+	s.logMu.Lock()
+	defer s.logMu.Unlock()
+
+	_, err = s.cfg.AccessLog.Write([]byte(line))
+	if err != nil {
+		log.Printf("blog: access log: %v", err)
+	}
+}
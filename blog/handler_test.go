@@ -0,0 +1,66 @@
+package blog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandlerForwardedHeaders(t *testing.T) {
+	tests := []struct {
+		name         string
+		proto, host  []string
+		wantStatus   int
+		wantLocation string
+	}{
+		{"https via proxy", []string{"https"}, []string{"example.com"}, http.StatusOK, ""},
+		{"plain http redirected", []string{"http"}, []string{"example.com"}, http.StatusMovedPermanently, "https://example.com/hello"},
+		{"spoofed host ignored", []string{"evil.example, http"}, []string{"evil.example, example.com"}, http.StatusMovedPermanently, "https://example.com/hello"},
+		{"spoofed proto ignored", []string{"https, http"}, nil, http.StatusMovedPermanently, "https://example.com/hello"},
+		{"repeated headers", []string{"https", "http"}, []string{"evil.example", "example.com"}, http.StatusMovedPermanently, "https://example.com/hello"},
+	}
+
+	for _, tt := range tests {
+		s := testServer("")
+		s.cfg.TrustProxyHeaders = true
+		s.cfg.ForceHTTPS = true
+
+		r := httptest.NewRequest("GET", "http://example.com/hello", nil)
+		for _, v := range tt.proto {
+			r.Header.Add("X-Forwarded-Proto", v)
+		}
+		for _, v := range tt.host {
+			r.Header.Add("X-Forwarded-Host", v)
+		}
+
+		scheme, host := s.origin(r)
+		if tt.wantStatus == http.StatusOK {
+			if scheme != "https" || host != "example.com" {
+				t.Errorf("%s: origin = %s://%s, want https://example.com", tt.name, scheme, host)
+			}
+			continue
+		}
+
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+
+		if w.Code != tt.wantStatus || w.Header().Get("Location") != tt.wantLocation {
+			t.Errorf("%s: got %d to %q, want %d to %q", tt.name, w.Code, w.Header().Get("Location"), tt.wantStatus, tt.wantLocation)
+		}
+	}
+
+	// Without TrustProxyHeaders the headers are ignored altogether.
+	s := testServer("")
+	s.cfg.ForceHTTPS = true
+
+	r := httptest.NewRequest("GET", "http://example.com/hello", nil)
+	r.Header.Set("X-Forwarded-Proto", "https")
+	r.Header.Set("X-Forwarded-Host", "evil.example")
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, r)
+
+	if loc := w.Header().Get("Location"); w.Code != http.StatusMovedPermanently || loc != "https://example.com/hello" {
+		t.Errorf("untrusted headers: got %d to %q, want 301 to https://example.com/hello", w.Code, loc)
+	}
+}
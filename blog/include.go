@@ -0,0 +1,142 @@
+package blog
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ryank90/utilities/present"
+)
+
+func init() {
+	present.Register("include", parseInclude)
+}
+
+// IncludeElem: marks where a shared snippet is spliced into an article. It is
+// replaced by the snippet's HTML before the article is rendered.
+
+type includeElem struct {
+	Name string
+}
+
+func (includeElem) TemplateName() string { return "include" }
+
+// ParseInclude: parses an ".include name" invocation, naming a snippet file
+// relative to the IncludesPath.
+
+func parseInclude(_ *present.Context, _ string, _ int, text string) (present.Elem, error) {
+	args := strings.Fields(text)
+	if len(args) != 2 {
+		return nil, fmt.Errorf("invalid .include args %q", text)
+	}
+
+	return includeElem{Name: args[1]}, nil
+}
+
+// IncludesDigest: returns a digest of the snippets under the IncludesPath, so
+// that articles are parsed again when a snippet they may include changes.
+
+func (s *Server) includesDigest() ([]byte, error) {
+	if s.cfg.IncludesPath == "" {
+		return nil, nil
+	}
+
+	h := sha256.New()
+
+	err := filepath.WalkDir(s.cfg.IncludesPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(h, "%s\x00%d\x00", p, len(b))
+		h.Write(b)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// ExpandIncludes: replaces the include elements of the sections, including
+// those of their subsections, with the HTML of the included snippets.
+
+func (s *Server) expandIncludes(sections []present.Section) error {
+	for i := range sections {
+		for j, elem := range sections[i].Elem {
+			switch e := elem.(type) {
+			case includeElem:
+				b, err := s.include(e.Name, nil)
+				if err != nil {
+					return err
+				}
+
+				sections[i].Elem[j] = present.HTML{HTML: template.HTML(b)}
+			case present.Section:
+				sub := []present.Section{e}
+
+				err := s.expandIncludes(sub)
+				if err != nil {
+					return err
+				}
+
+				sections[i].Elem[j] = sub[0]
+			}
+		}
+	}
+
+	return nil
+}
+
+// Include: returns the snippet of the given name from the IncludesPath with
+// its own ".include name" lines expanded. The stack holds the names of the
+// snippets being included so that cycles are reported instead of recursing.
+
+func (s *Server) include(name string, stack []string) (string, error) {
+	if s.cfg.IncludesPath == "" {
+		return "", fmt.Errorf("blog: include %q: Config.IncludesPath is not set", name)
+	}
+
+	if !fs.ValidPath(name) {
+		return "", fmt.Errorf("blog: include %q: invalid name", name)
+	}
+
+	for _, n := range stack {
+		if n == name {
+			return "", fmt.Errorf("blog: include cycle: %s -> %s", strings.Join(stack, " -> "), name)
+		}
+	}
+
+	b, err := os.ReadFile(filepath.Join(s.cfg.IncludesPath, filepath.FromSlash(name)))
+	if err != nil {
+		return "", fmt.Errorf("blog: include %q: %w", name, err)
+	}
+
+	stack = append(stack, name)
+	lines := strings.SplitAfter(string(b), "\n")
+
+	for i, line := range lines {
+		args := strings.Fields(line)
+		if len(args) != 2 || args[0] != ".include" {
+			continue
+		}
+
+		lines[i], err = s.include(args[1], stack)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return strings.Join(lines, ""), nil
+}
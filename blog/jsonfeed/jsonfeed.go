@@ -0,0 +1,38 @@
+package jsonfeed
+
+import (
+	"time"
+)
+
+// Version is the JSON Feed specification version implemented by this package.
+const Version = "https://jsonfeed.org/version/1.1"
+
+type Feed struct {
+	Version     string    `json:"version"`
+	Title       string    `json:"title"`
+	HomePageURL string    `json:"home_page_url,omitempty"`
+	FeedURL     string    `json:"feed_url,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Rights      string    `json:"_rights,omitempty"`
+	Authors     []*Author `json:"authors,omitempty"`
+	Items       []*Item   `json:"items"`
+}
+
+type Item struct {
+	ID            string    `json:"id"`
+	URL           string    `json:"url,omitempty"`
+	Title         string    `json:"title,omitempty"`
+	ContentHTML   string    `json:"content_html,omitempty"`
+	Summary       string    `json:"summary,omitempty"`
+	Image         string    `json:"image,omitempty"`
+	DatePublished time.Time `json:"date_published"`
+	Authors       []*Author `json:"authors,omitempty"`
+	Tags          []string  `json:"tags,omitempty"`
+	Rights        string    `json:"_rights,omitempty"`
+}
+
+type Author struct {
+	Name   string `json:"name,omitempty"`
+	URL    string `json:"url,omitempty"`
+	Avatar string `json:"avatar,omitempty"`
+}
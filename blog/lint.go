@@ -0,0 +1,58 @@
+package blog
+
+import (
+	"sort"
+	"strings"
+)
+
+// Issues reported by Lint.
+
+const (
+	LintMissingTitle   = "missing title"
+	LintMissingDate    = "missing date"
+	LintMissingTags    = "missing tags"
+	LintMissingSummary = "missing summary"
+)
+
+// LintIssue: records an article lacking metadata.
+
+type LintIssue struct {
+	File  string // Article file.
+	Path  string // Path the article is served at.
+	Issue string // One of the Lint* issues.
+}
+
+func (i LintIssue) String() string {
+	return i.File + ": " + i.Issue
+}
+
+// Lint: inspects the loaded articles, reporting those without a title, a date,
+// tags or a summary, ordered by file.
+
+func (s *Server) Lint() []LintIssue {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var issues []LintIssue
+
+	for file, src := range s.sources {
+		doc := src.doc
+
+		check := func(missing bool, issue string) {
+			if missing {
+				issues = append(issues, LintIssue{File: file, Path: doc.Path, Issue: issue})
+			}
+		}
+
+		check(strings.TrimSpace(doc.Title) == "", LintMissingTitle)
+		check(doc.Time.IsZero(), LintMissingDate)
+		check(len(doc.Tags) == 0, LintMissingTags)
+		check(strings.TrimSpace(stripTags(string(doc.Summary))) == "", LintMissingSummary)
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].File < issues[j].File
+	})
+
+	return issues
+}
@@ -0,0 +1,238 @@
+package blog
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// Size of the OpenGraph image and of its margins, in pixels.
+
+const (
+	ogWidth  = 1200
+	ogHeight = 630
+	ogMargin = 80
+)
+
+// Colours of the OpenGraph image.
+
+var (
+	ogBackground = color.RGBA{0x1f, 0x29, 0x37, 0xff}
+	ogAccent     = color.RGBA{0x38, 0xbd, 0xf8, 0xff}
+	ogText       = color.RGBA{0xf9, 0xfa, 0xfb, 0xff}
+	ogMuted      = color.RGBA{0x9c, 0xa3, 0xaf, 0xff}
+)
+
+// Glyphs: a 5x7 bitmap font, one byte per row with the leftmost pixel in bit 4.
+// Letters are drawn in upper case.
+
+var glyphs = map[rune][7]uint8{
+	'A':  {0b01110, 0b10001, 0b10001, 0b11111, 0b10001, 0b10001, 0b10001},
+	'B':  {0b11110, 0b10001, 0b10001, 0b11110, 0b10001, 0b10001, 0b11110},
+	'C':  {0b01110, 0b10001, 0b10000, 0b10000, 0b10000, 0b10001, 0b01110},
+	'D':  {0b11100, 0b10010, 0b10001, 0b10001, 0b10001, 0b10010, 0b11100},
+	'E':  {0b11111, 0b10000, 0b10000, 0b11110, 0b10000, 0b10000, 0b11111},
+	'F':  {0b11111, 0b10000, 0b10000, 0b11110, 0b10000, 0b10000, 0b10000},
+	'G':  {0b01110, 0b10001, 0b10000, 0b10111, 0b10001, 0b10001, 0b01111},
+	'H':  {0b10001, 0b10001, 0b10001, 0b11111, 0b10001, 0b10001, 0b10001},
+	'I':  {0b01110, 0b00100, 0b00100, 0b00100, 0b00100, 0b00100, 0b01110},
+	'J':  {0b00111, 0b00010, 0b00010, 0b00010, 0b00010, 0b10010, 0b01100},
+	'K':  {0b10001, 0b10010, 0b10100, 0b11000, 0b10100, 0b10010, 0b10001},
+	'L':  {0b10000, 0b10000, 0b10000, 0b10000, 0b10000, 0b10000, 0b11111},
+	'M':  {0b10001, 0b11011, 0b10101, 0b10101, 0b10001, 0b10001, 0b10001},
+	'N':  {0b10001, 0b10001, 0b11001, 0b10101, 0b10011, 0b10001, 0b10001},
+	'O':  {0b01110, 0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b01110},
+	'P':  {0b11110, 0b10001, 0b10001, 0b11110, 0b10000, 0b10000, 0b10000},
+	'Q':  {0b01110, 0b10001, 0b10001, 0b10001, 0b10101, 0b10010, 0b01101},
+	'R':  {0b11110, 0b10001, 0b10001, 0b11110, 0b10100, 0b10010, 0b10001},
+	'S':  {0b01111, 0b10000, 0b10000, 0b01110, 0b00001, 0b00001, 0b11110},
+	'T':  {0b11111, 0b00100, 0b00100, 0b00100, 0b00100, 0b00100, 0b00100},
+	'U':  {0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b01110},
+	'V':  {0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b01010, 0b00100},
+	'W':  {0b10001, 0b10001, 0b10001, 0b10101, 0b10101, 0b10101, 0b01010},
+	'X':  {0b10001, 0b10001, 0b01010, 0b00100, 0b01010, 0b10001, 0b10001},
+	'Y':  {0b10001, 0b10001, 0b10001, 0b01010, 0b00100, 0b00100, 0b00100},
+	'Z':  {0b11111, 0b00001, 0b00010, 0b00100, 0b01000, 0b10000, 0b11111},
+	'0':  {0b01110, 0b10001, 0b10011, 0b10101, 0b11001, 0b10001, 0b01110},
+	'1':  {0b00100, 0b01100, 0b00100, 0b00100, 0b00100, 0b00100, 0b01110},
+	'2':  {0b01110, 0b10001, 0b00001, 0b00010, 0b00100, 0b01000, 0b11111},
+	'3':  {0b11111, 0b00010, 0b00100, 0b00010, 0b00001, 0b10001, 0b01110},
+	'4':  {0b00010, 0b00110, 0b01010, 0b10010, 0b11111, 0b00010, 0b00010},
+	'5':  {0b11111, 0b10000, 0b11110, 0b00001, 0b00001, 0b10001, 0b01110},
+	'6':  {0b00110, 0b01000, 0b10000, 0b11110, 0b10001, 0b10001, 0b01110},
+	'7':  {0b11111, 0b00001, 0b00010, 0b00100, 0b01000, 0b01000, 0b01000},
+	'8':  {0b01110, 0b10001, 0b10001, 0b01110, 0b10001, 0b10001, 0b01110},
+	'9':  {0b01110, 0b10001, 0b10001, 0b01111, 0b00001, 0b00010, 0b01100},
+	' ':  {},
+	'.':  {0, 0, 0, 0, 0, 0b01100, 0b01100},
+	',':  {0, 0, 0, 0, 0b01100, 0b00100, 0b01000},
+	'!':  {0b00100, 0b00100, 0b00100, 0b00100, 0b00100, 0, 0b00100},
+	'?':  {0b01110, 0b10001, 0b00001, 0b00010, 0b00100, 0, 0b00100},
+	'-':  {0, 0, 0, 0b11111, 0, 0, 0},
+	'+':  {0, 0b00100, 0b00100, 0b11111, 0b00100, 0b00100, 0},
+	':':  {0, 0b01100, 0b01100, 0, 0b01100, 0b01100, 0},
+	'/':  {0, 0b00001, 0b00010, 0b00100, 0b01000, 0b10000, 0},
+	'(':  {0b00010, 0b00100, 0b01000, 0b01000, 0b01000, 0b00100, 0b00010},
+	')':  {0b01000, 0b00100, 0b00010, 0b00010, 0b00010, 0b00100, 0b01000},
+	'&':  {0b01100, 0b10010, 0b10100, 0b01000, 0b10101, 0b10010, 0b01101},
+	'#':  {0b01010, 0b01010, 0b11111, 0b01010, 0b11111, 0b01010, 0b01010},
+	'\'': {0b01100, 0b00100, 0b01000, 0, 0, 0, 0},
+	'"':  {0b01010, 0b01010, 0b01010, 0, 0, 0, 0},
+}
+
+// RenderOGImage: renders the OpenGraph image of the article at path (relative
+// to the BasePath) to w as a PNG: a card holding its title, authors and the
+// FeedTitle of the site.
+
+func (s *Server) RenderOGImage(w io.Writer, path string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	doc, ok := s.docPaths[path]
+	if !ok {
+		return fmt.Errorf("blog: no article at path %q", path)
+	}
+
+	img, err := s.ogImage(path, doc)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(img)
+	return err
+}
+
+// OgImage: returns the OpenGraph image of the Doc (Article) at path (relative
+// to the BasePath), rendering it once per load. The caller must hold the read
+// lock.
+
+func (s *Server) ogImage(path string, doc *Doc) ([]byte, error) {
+	s.pagesMu.Lock()
+	img, ok := s.ogImages[path]
+	s.pagesMu.Unlock()
+
+	if ok {
+		return img, nil
+	}
+
+	var b bytes.Buffer
+
+	err := s.renderOGImage(&b, doc)
+	if err != nil {
+		return nil, err
+	}
+
+	s.pagesMu.Lock()
+	defer s.pagesMu.Unlock()
+
+	if s.ogImages == nil {
+		s.ogImages = make(map[string][]byte)
+	}
+
+	s.ogImages[path] = b.Bytes()
+
+	return b.Bytes(), nil
+}
+
+// RenderOGImage: renders the OpenGraph image of the Doc (Article) to w. The
+// caller must hold the read lock.
+
+func (s *Server) renderOGImage(w io.Writer, doc *Doc) error {
+	img := image.NewRGBA(image.Rect(0, 0, ogWidth, ogHeight))
+
+	draw.Draw(img, img.Bounds(), image.NewUniform(ogBackground), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, 16, ogHeight), image.NewUniform(ogAccent), image.Point{}, draw.Src)
+
+	const titleScale = 7
+
+	y := ogMargin
+
+	for _, line := range wrapText(doc.Title, (ogWidth-2*ogMargin)/(6*titleScale), 4) {
+		drawText(img, ogMargin, y, titleScale, line, ogText)
+		y += 10 * titleScale
+	}
+
+	drawText(img, ogMargin, ogHeight-ogMargin-7*3-16*4, 4, authors(doc.Authors), ogText)
+	drawText(img, ogMargin, ogHeight-ogMargin-7*3, 3, s.cfg.FeedTitle, ogMuted)
+
+	return png.Encode(w, img)
+}
+
+// WrapText: splits text into at most n lines of up to width characters, breaking
+// between words and ending in an ellipsis when it does not fit.
+
+func wrapText(text string, width, n int) []string {
+	var lines []string
+
+	line := ""
+
+	for _, word := range strings.Fields(text) {
+		switch {
+		case line == "":
+			line = word
+		case len([]rune(line))+1+len([]rune(word)) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	if len(lines) > n {
+		lines = lines[:n]
+
+		last := []rune(lines[n-1])
+		if len(last) > width-3 {
+			last = last[:width-3]
+		}
+
+		lines[n-1] = string(last) + "..."
+	}
+
+	return lines
+}
+
+// DrawText: draws text with the bitmap font onto img, its top left corner at
+// x, y and each font pixel scaled to a square of scale pixels.
+
+func drawText(img draw.Image, x, y, scale int, text string, c color.Color) {
+	fill := image.NewUniform(c)
+
+	for _, r := range text {
+		for row, bits := range glyph(r) {
+			for col := 0; col < 5; col++ {
+				if bits&(1<<(4-col)) != 0 {
+					px, py := x+col*scale, y+row*scale
+					draw.Draw(img, image.Rect(px, py, px+scale, py+scale), fill, image.Point{}, draw.Src)
+				}
+			}
+		}
+
+		x += 6 * scale
+	}
+}
+
+// Glyph: returns the bitmap of r, folding accented letters to ASCII and using
+// a question mark for characters the font lacks.
+
+func glyph(r rune) [7]uint8 {
+	if g, ok := glyphs[unicode.ToUpper(r)]; ok {
+		return g
+	}
+
+	if f, ok := folds[unicode.ToLower(r)]; ok {
+		return glyph([]rune(f)[0])
+	}
+
+	return glyphs['?']
+}
@@ -0,0 +1,41 @@
+package blog
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOGImageCachedPerLoad(t *testing.T) {
+	s := loadTestServer(t, Config{}, map[string]string{
+		"a.article": testArticle("A", "1 Jan 2013"),
+	})
+
+	var first, second bytes.Buffer
+
+	err := s.RenderOGImage(&first, "/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := s.ogImages["/a"]; !ok {
+		t.Fatal("image of /a not cached")
+	}
+
+	err = s.RenderOGImage(&second, "/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Error("cached image differs from the rendered one")
+	}
+
+	_, err = s.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.ogImages != nil {
+		t.Errorf("images = %d after load, want none", len(s.ogImages))
+	}
+}
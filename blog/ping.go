@@ -0,0 +1,48 @@
+package blog
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Timeout of each search engine ping.
+
+const pingTimeout = 5 * time.Second
+
+// SitemapURL: returns the configured SitemapURL, defaulting to the sitemap the
+// Server renders at /sitemap.xml.
+
+func (s *Server) sitemapURL() string {
+	if s.cfg.SitemapURL != "" {
+		return s.cfg.SitemapURL
+	}
+
+	return s.mountedURL("/sitemap.xml")
+}
+
+// Ping: requests each of the PingOnReload URLs with "{sitemap}" replaced by the
+// escaped sitemap URL. Pinging is best-effort, so failures are only logged.
+
+func (s *Server) ping() {
+	client := &http.Client{Timeout: pingTimeout}
+	sitemap := url.QueryEscape(s.sitemapURL())
+
+	for _, target := range s.cfg.PingOnReload {
+		target = strings.ReplaceAll(target, "{sitemap}", sitemap)
+
+		resp, err := client.Get(target)
+		if err != nil {
+			log.Printf("blog: ping %s: %v", target, err)
+			continue
+		}
+
+		resp.Body.Close()
+
+		if resp.StatusCode >= 400 {
+			log.Printf("blog: ping %s: %s", target, resp.Status)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package blog
+
+import (
+	"html"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/ryank90/utilities/present"
+)
+
+// Matches the src of img elements and the href of a elements in rendered articles.
+
+var (
+	imgSrc  = regexp.MustCompile(`(<img\b[^>]*?\bsrc=")([^"]*)(")`)
+	linkRef = regexp.MustCompile(`(<a\b[^>]*?\bhref=")([^"]*)(")`)
+)
+
+// Matches an HTML start or end tag.
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// StripTags: returns the text of the HTML fragment with its markup removed.
+
+func stripTags(s string) string {
+	return html.UnescapeString(htmlTag.ReplaceAllString(s, ""))
+}
+
+// Marker standing in for the more anchor while an article is rendered. It is
+// taken from the Unicode private use area, so articles do not contain it.
+
+const moreMarker = "\uE000"
+
+// MoreID: returns the id of the anchor marking where the article from the
+// source file continues after its summary, unique among the articles so they
+// can share a page.
+
+func moreID(source string) string {
+	return "more-" + Slugify(strings.TrimSuffix(source, path.Ext(source)))
+}
+
+// MarkMore: returns a copy of the Doc (Article) with the more marker after the
+// paragraph ending the summary from its text, reporting whether it was placed.
+// Summaries from the metadata and ones holding the whole text are not marked.
+
+func (s *Server) markMore(d *present.Doc) (*present.Doc, bool) {
+	i, j, ok := s.summaryEnd(d)
+	if !ok {
+		return d, false
+	}
+
+	text := d.Sections[i].Elem[j].(present.Text)
+	text.Lines = append([]string(nil), text.Lines...)
+	text.Lines[len(text.Lines)-1] += moreMarker
+
+	marked := *d
+	marked.Sections = append([]present.Section(nil), d.Sections...)
+	marked.Sections[i].Elem = append([]present.Elem(nil), d.Sections[i].Elem...)
+	marked.Sections[i].Elem[j] = text
+
+	return &marked, true
+}
+
+// SummaryEnd: returns the section and element indexes of the paragraph where
+// the summary of the Doc (Article) following the SummaryStrategy ends, if the
+// text continues past it.
+
+func (s *Server) summaryEnd(d *present.Doc) (int, int, bool) {
+	if d.Metadata["summary"] != "" || s.cfg.SummaryStrategy == "explicit" {
+		return 0, 0, false
+	}
+
+	n := s.cfg.SummaryWords
+	if n == 0 {
+		n = defaultSummaryWords
+	}
+
+	words := 0
+
+	for i, section := range d.Sections {
+		for j, elem := range section.Elem {
+			text, ok := elem.(present.Text)
+			if !ok || text.Pre || len(text.Lines) == 0 {
+				continue
+			}
+
+			if s.cfg.SummaryStrategy != "first-n-words" {
+				// The first paragraph, as long as anything follows it.
+				more := j < len(section.Elem)-1 || i < len(d.Sections)-1
+				return i, j, i == 0 && more
+			}
+
+			for _, line := range text.Lines {
+				words += len(strings.Fields(stripTags(string(present.Style(line)))))
+			}
+
+			if words > n {
+				return i, j, true
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// RewriteAssets: applies fn to the src of every image and to the href of every
+// link to an asset (a file other than a page) in the rendered HTML.
+
+func rewriteAssets(s string, fn func(string) string) string {
+	s = rewriteAttr(s, imgSrc, fn)
+
+	return rewriteAttr(s, linkRef, func(ref string) string {
+		if !isAsset(ref) {
+			return ref
+		}
+
+		return fn(ref)
+	})
+}
+
+// RewriteAttr: replaces the attribute values captured by re with fn applied to
+// their unescaped form.
+
+func rewriteAttr(s string, re *regexp.Regexp, fn func(string) string) string {
+	return re.ReplaceAllStringFunc(s, func(m string) string {
+		sub := re.FindStringSubmatch(m)
+		ref := fn(html.UnescapeString(sub[2]))
+
+		return sub[1] + html.EscapeString(ref) + sub[3]
+	})
+}
+
+// IsAsset: reports whether the reference points at a file with an extension
+// other than an HTML page's.
+
+func isAsset(ref string) bool {
+	if i := strings.IndexAny(ref, "?#"); i >= 0 {
+		ref = ref[:i]
+	}
+
+	switch ext := strings.ToLower(path.Ext(ref)); ext {
+	case "", ".html", ".htm":
+		return false
+	}
+
+	// A host name such as "http://example.com" is not a file.
+	if i := strings.Index(ref, "://"); i >= 0 && !strings.Contains(ref[i+3:], "/") {
+		return false
+	}
+
+	return true
+}
+
+// RewriteRelative: roots the relative src of every image and href of every
+// link in the rendered HTML at dir. Absolute, protocol-relative and
+// fragment-only references are left alone.
+
+func rewriteRelative(s, dir string) string {
+	fn := func(ref string) string {
+		if ref == "" || strings.HasPrefix(ref, "/") || strings.HasPrefix(ref, "#") {
+			return ref
+		}
+
+		if u, err := url.Parse(ref); err != nil || u.Scheme != "" {
+			return ref
+		}
+
+		suffix := ""
+		if i := strings.IndexAny(ref, "?#"); i >= 0 {
+			ref, suffix = ref[:i], ref[i:]
+		}
+
+		return path.Join(dir, ref) + suffix
+	}
+
+	return rewriteAttr(rewriteAttr(s, imgSrc, fn), linkRef, fn)
+}
+
+// Matches the headings and white space that may precede the first paragraph.
+
+var leadingHeadings = regexp.MustCompile(`^(\s*<h[1-6][^>]*>.*?</h[1-6]>)*\s*`)
+
+// MarkLede: adds class to the first paragraph of the rendered HTML, unless the
+// article starts with an element other than a paragraph after its headings.
+
+func markLede(s, class string) string {
+	i := len(leadingHeadings.FindString(s))
+
+	if !strings.HasPrefix(s[i:], "<p>") {
+		return s
+	}
+
+	return s[:i] + `<p class="` + html.EscapeString(class) + `">` + s[i+len("<p>"):]
+}
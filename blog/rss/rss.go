@@ -0,0 +1,43 @@
+package rss
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+type Feed struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	Channel *Channel `xml:"channel"`
+}
+
+type Channel struct {
+	Title           string  `xml:"title"`
+	Link            string  `xml:"link"`
+	Description     string  `xml:"description"`
+	Copyright       string  `xml:"copyright,omitempty"`
+	LastBuildDate   TimeStr `xml:"lastBuildDate,omitempty"`
+	TTL             int     `xml:"ttl,omitempty"` // Minutes the channel may be cached.
+	UpdatePeriod    string  `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod,omitempty"`
+	UpdateFrequency int     `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency,omitempty"`
+	Item            []*Item `xml:"item"`
+}
+
+type Item struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        *GUID   `xml:"guid"`
+	PubDate     TimeStr `xml:"pubDate"`
+	Description string  `xml:"description"`
+}
+
+type GUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type TimeStr string
+
+func Time(t time.Time) TimeStr {
+	return TimeStr(t.Format(time.RFC1123Z))
+}
@@ -0,0 +1,61 @@
+package blog
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// Sitemap document types, following https://www.sitemaps.org/protocol.html.
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URL     []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// RenderSitemap: generates the sitemap of the home page and the articles that
+// may be indexed under their own permalink, and stores it in the Server's
+// sitemap field.
+
+func (s *Server) renderSitemap() error {
+	var (
+		urls    []sitemapURL
+		updated time.Time
+	)
+
+	for _, doc := range s.docs {
+		if doc.NoIndex || canonical(doc) != doc.Permalink {
+			continue
+		}
+
+		t := lastChanged(doc)
+		if t.After(updated) {
+			updated = t
+		}
+
+		urls = append(urls, sitemapURL{Loc: doc.Permalink, LastMod: sitemapTime(t)})
+	}
+
+	home := sitemapURL{Loc: s.mountedURL("/")}
+	if !updated.IsZero() {
+		home.LastMod = sitemapTime(updated)
+	}
+
+	data, err := xml.Marshal(&sitemapURLSet{URL: append([]sitemapURL{home}, urls...)})
+	if err != nil {
+		return err
+	}
+
+	s.sitemap = append([]byte(xml.Header), data...)
+	return nil
+}
+
+// SitemapTime: formats t as a W3C date for a lastmod element.
+
+func sitemapTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
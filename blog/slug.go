@@ -0,0 +1,152 @@
+package blog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Folds: ASCII replacements for common accented Latin letters.
+
+var folds = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'æ': "ae",
+	'ç': "c", 'ð': "d", 'đ': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i",
+	'ł': "l", 'ñ': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'œ': "oe",
+	'ß': "ss", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u",
+	'ý': "y", 'ÿ': "y",
+}
+
+// Slugify: returns s as a URL slug: lower-cased, with accented Latin letters
+// folded to ASCII and every run of other characters replaced by a single
+// hyphen. Letters that cannot be folded are kept.
+
+func Slugify(s string) string {
+	var b strings.Builder
+
+	pending := false
+
+	for _, r := range strings.ToLower(s) {
+		f, ok := folds[r]
+
+		switch {
+		case ok:
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			f = string(r)
+		default:
+			pending = b.Len() > 0
+			continue
+		}
+
+		if pending {
+			b.WriteByte('-')
+			pending = false
+		}
+
+		b.WriteString(f)
+	}
+
+	return b.String()
+}
+
+// SlugifyPath: applies Slugify to each segment of the slash-separated path.
+
+func slugifyPath(p string) string {
+	segs := strings.Split(p, "/")
+
+	for i, seg := range segs {
+		segs[i] = Slugify(seg)
+	}
+
+	return strings.Join(segs, "/")
+}
+
+// ResolveSlugCollisions: gives the Docs (Articles) their paths, following the
+// SlugCollision mode for articles sharing one. The oldest article keeps the
+// path, so the URLs of published articles stay put as others are added. In
+// "error" mode the later articles are reported instead.
+
+func (s *Server) resolveSlugCollisions(docs []*Doc) []LoadError {
+	byPath := make(map[string][]*Doc)
+	taken := make(map[string]bool)
+
+	var paths []string
+
+	for _, doc := range docs {
+		s.setDocPath(doc, doc.slugPath)
+
+		if byPath[doc.slugPath] == nil {
+			paths = append(paths, doc.slugPath)
+		}
+
+		byPath[doc.slugPath] = append(byPath[doc.slugPath], doc)
+		taken[doc.slugPath] = true
+	}
+
+	if s.cfg.SlugCollision == "" {
+		return nil
+	}
+
+	sort.Strings(paths)
+
+	var errs []LoadError
+
+	for _, p := range paths {
+		group := byPath[p]
+
+		if len(group) < 2 {
+			continue
+		}
+
+		sort.SliceStable(group, func(i, j int) bool {
+			if !group[i].Time.Equal(group[j].Time) {
+				return group[i].Time.Before(group[j].Time)
+			}
+
+			return group[i].Source < group[j].Source
+		})
+
+		for i, doc := range group[1:] {
+			var q string
+
+			switch s.cfg.SlugCollision {
+			case "error":
+				err := fmt.Errorf("blog: path %s is also that of %s", doc.Path, group[0].Source)
+				errs = append(errs, LoadError{File: s.sourceName(doc.Source), Err: err})
+				continue
+			case "date-suffix":
+				q = p + doc.Time.Format("-2006-01-02")
+			case "numeric-suffix":
+				q = fmt.Sprintf("%s-%d", p, i+2)
+			}
+
+			// Suffixed paths may still collide, e.g. articles of the same day.
+			base := q
+
+			for n := 2; taken[q]; n++ {
+				q = fmt.Sprintf("%s-%d", base, n)
+			}
+
+			taken[q] = true
+			s.setDocPath(doc, q)
+		}
+	}
+
+	return errs
+}
+
+// SetDocPath: sets the Path and Permalink of the Doc (Article) served at p,
+// relative to the ArticlePrefix, the Permalink following TrailingSlash.
+
+func (s *Server) setDocPath(doc *Doc, p string) {
+	doc.Path = s.cfg.BasePath + s.cfg.ArticlePrefix + p
+	doc.Permalink = s.mountedURL(s.cfg.ArticlePrefix + p)
+
+	if s.cfg.TrailingSlash {
+		doc.Permalink += "/"
+	}
+}
@@ -0,0 +1,60 @@
+package blog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ryank90/utilities/present"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"hello", "hello"},
+		{"Hello World", "hello-world"},
+		{"  Go: the Good Parts! ", "go-the-good-parts"},
+		{"Crème Brûlée", "creme-brulee"},
+		{"Straße_2013", "strasse-2013"},
+		{"a--b__c", "a-b-c"},
+		{"日本語 post", "日本語-post"},
+		{"---", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Slugify(tt.in); got != tt.want {
+			t.Errorf("Slugify(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestResolveSlugCollisions(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2013, 1, d, 10, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		mode string
+		want []string
+	}{
+		{"date-suffix", []string{"/a-2013-01-03-2", "/a", "/a-2013-01-03"}},
+		{"numeric-suffix", []string{"/a-3", "/a", "/a-2"}},
+	}
+
+	for _, tt := range tests {
+		s := &Server{cfg: Config{SlugCollision: tt.mode}}
+		docs := []*Doc{
+			{Doc: &present.Doc{Time: day(3)}, Source: "c.article", slugPath: "/a"},
+			{Doc: &present.Doc{Time: day(1)}, Source: "a.article", slugPath: "/a"},
+			{Doc: &present.Doc{Time: day(3)}, Source: "b.article", slugPath: "/a"},
+		}
+
+		if errs := s.resolveSlugCollisions(docs); len(errs) > 0 {
+			t.Fatalf("%s: unexpected errors %v", tt.mode, errs)
+		}
+
+		for i, doc := range docs {
+			if doc.Path != tt.want[i] {
+				t.Errorf("%s: %s got path %q, want %q", tt.mode, doc.Source, doc.Path, tt.want[i])
+			}
+		}
+	}
+}
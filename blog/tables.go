@@ -0,0 +1,104 @@
+package blog
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+	"strings"
+
+	"github.com/ryank90/utilities/present"
+)
+
+// Matches a cell of a pipe table's header separator row, such as "---" or ":-:".
+
+var tableSeparator = regexp.MustCompile(`^:?-+:?$`)
+
+// RenderTables: replaces paragraphs written as pipe tables in the sections,
+// and their subsections, with HTML tables. Preformatted text is left alone.
+//
+//	| Name | Value |
+//	|------|-------|
+//	| a    | 1     |
+
+func renderTables(sections []present.Section) {
+	for i := range sections {
+		for j, e := range sections[i].Elem {
+			switch e := e.(type) {
+			case present.Text:
+				if table, ok := pipeTable(e); ok {
+					sections[i].Elem[j] = table
+				}
+			case present.Section:
+				sub := []present.Section{e}
+				renderTables(sub)
+				sections[i].Elem[j] = sub[0]
+			}
+		}
+	}
+}
+
+// PipeTable: returns the text as an HTML table if every line is a table row.
+
+func pipeTable(text present.Text) (present.HTML, bool) {
+	if text.Pre || len(text.Lines) < 2 {
+		return present.HTML{}, false
+	}
+
+	var rows [][]string
+
+	for _, line := range text.Lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "|") || !strings.HasSuffix(line, "|") || len(line) < 2 {
+			return present.HTML{}, false
+		}
+
+		cells := strings.Split(line[1:len(line)-1], "|")
+		for i := range cells {
+			cells[i] = strings.TrimSpace(cells[i])
+		}
+
+		rows = append(rows, cells)
+	}
+
+	// A separator as the second row makes the first row the header.
+	header := true
+
+	for _, cell := range rows[1] {
+		if !tableSeparator.MatchString(cell) {
+			header = false
+			break
+		}
+	}
+
+	var b bytes.Buffer
+
+	b.WriteString("<table>\n")
+
+	if header {
+		writeTableRow(&b, "th", rows[0])
+		rows = rows[2:]
+	}
+
+	for _, row := range rows {
+		writeTableRow(&b, "td", row)
+	}
+
+	b.WriteString("</table>\n")
+
+	return present.HTML{HTML: template.HTML(b.String())}, true
+}
+
+// WriteTableRow: writes the cells as a table row of the element, styled as
+// present text.
+
+func writeTableRow(b *bytes.Buffer, elem string, cells []string) {
+	b.WriteString("<tr>")
+
+	for _, cell := range cells {
+		b.WriteString("<" + elem + ">")
+		b.WriteString(string(present.Style(cell)))
+		b.WriteString("</" + elem + ">")
+	}
+
+	b.WriteString("</tr>\n")
+}
@@ -0,0 +1,140 @@
+package blog
+
+import (
+	"log"
+	"sort"
+	"strings"
+)
+
+// ViewStore: persists the view counts of articles so they survive restarts.
+// Paths are relative to the BasePath.
+
+type ViewStore interface {
+	LoadViews() (map[string]int, error) // Returns the saved counts keyed by path.
+	SaveView(path string, views int) error
+}
+
+// RecordView: counts a view of the article at path, with or without the
+// BasePath. Paths of unknown articles are ignored.
+
+func (s *Server) RecordView(path string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p := strings.TrimPrefix(path, s.cfg.BasePath)
+
+	if _, ok := s.docPaths[p]; ok {
+		s.recordView(p)
+	}
+}
+
+// Views: returns the amount of views of the article at path, with or without
+// the BasePath.
+
+func (s *Server) Views(path string) int {
+	s.viewsMu.Lock()
+	defer s.viewsMu.Unlock()
+
+	return s.views[strings.TrimPrefix(path, s.cfg.BasePath)]
+}
+
+// RecordView: counts a view of the article at p (relative to the BasePath).
+// The count is saved to the ViewStore, if any, in the background so a slow
+// store never holds up requests. The caller holds s.mu.
+
+func (s *Server) recordView(p string) {
+	s.viewsMu.Lock()
+	defer s.viewsMu.Unlock()
+
+	if s.views == nil {
+		s.views = make(map[string]int)
+	}
+
+	s.views[p]++
+
+	if s.cfg.ViewStore == nil {
+		return
+	}
+
+	if s.viewsDirty == nil {
+		s.viewsDirty = make(map[string]int)
+	}
+
+	s.viewsDirty[p] = s.views[p]
+
+	if !s.viewsSaving {
+		s.viewsSaving = true
+		go s.saveViews()
+	}
+}
+
+// SaveViews: saves the counts changed since the last save to the ViewStore,
+// batching the views recorded meanwhile, until none are left. At most one runs
+// at a time and it holds no lock while saving.
+
+func (s *Server) saveViews() {
+	for {
+		s.viewsMu.Lock()
+		dirty := s.viewsDirty
+		s.viewsDirty = nil
+		if len(dirty) == 0 {
+			s.viewsSaving = false
+		}
+		s.viewsMu.Unlock()
+
+		if len(dirty) == 0 {
+			return
+		}
+
+		for p, views := range dirty {
+			if err := s.cfg.ViewStore.SaveView(p, views); err != nil {
+				log.Printf("blog: saving views of %s: %v", p, err)
+			}
+		}
+	}
+}
+
+// LoadViews: restores the view counts from the ViewStore, if any.
+
+func (s *Server) loadViews() error {
+	if s.cfg.ViewStore == nil {
+		return nil
+	}
+
+	views, err := s.cfg.ViewStore.LoadViews()
+	if err != nil {
+		return err
+	}
+
+	s.viewsMu.Lock()
+	s.views = views
+	s.viewsMu.Unlock()
+
+	return nil
+}
+
+// PopularPosts: returns up to n of the most viewed Docs (Articles), most viewed
+// first and newest first among equals. Articles never viewed are left out.
+
+func (s *Server) popularPosts(n int) []*Doc {
+	s.viewsMu.Lock()
+	defer s.viewsMu.Unlock()
+
+	var docs []*Doc
+
+	for _, doc := range s.docs {
+		if s.views[strings.TrimPrefix(doc.Path, s.cfg.BasePath)] > 0 {
+			docs = append(docs, doc)
+		}
+	}
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		return s.views[strings.TrimPrefix(docs[i].Path, s.cfg.BasePath)] > s.views[strings.TrimPrefix(docs[j].Path, s.cfg.BasePath)]
+	})
+
+	if len(docs) > n {
+		docs = docs[:n]
+	}
+
+	return docs
+}
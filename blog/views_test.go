@@ -0,0 +1,77 @@
+package blog
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// BlockingStore: a ViewStore whose saves wait until release is closed.
+
+type blockingStore struct {
+	release chan struct{}
+	mu      sync.Mutex
+	saved   map[string]int
+}
+
+func (b *blockingStore) LoadViews() (map[string]int, error) {
+	return nil, nil
+}
+
+func (b *blockingStore) SaveView(path string, views int) error {
+	<-b.release
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.saved[path] = views
+
+	return nil
+}
+
+func TestRecordViewDoesNotWaitForStore(t *testing.T) {
+	store := &blockingStore{release: make(chan struct{}), saved: make(map[string]int)}
+
+	s := testServer("")
+	s.cfg.ViewStore = store
+	s.docPaths = map[string]*Doc{"/hello": s.docs[0]}
+
+	done := make(chan struct{})
+
+	go func() {
+		for i := 0; i < 3; i++ {
+			s.RecordView("/hello")
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RecordView blocked on a slow ViewStore")
+	}
+
+	if got := s.Views("/hello"); got != 3 {
+		t.Errorf("Views = %d, want 3", got)
+	}
+
+	close(store.release)
+
+	deadline := time.Now().Add(time.Second)
+
+	for {
+		store.mu.Lock()
+		saved := store.saved["/hello"]
+		store.mu.Unlock()
+
+		if saved == 3 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("saved views = %d, want 3", saved)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
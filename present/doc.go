@@ -28,6 +28,10 @@ In this case, the time will be interpreted as 10am UTC on that date.
 The tags line is a comma-separated list of tags that may be used to categorize
 the document.
 
+Programs may declare additional header keys with RegisterMetadata. Header lines
+of the form "key: value" whose key was registered are collected into the
+document's Metadata map, keyed by the lower-cased key.
+
 The author section may contain a mixture of text, twitter names, and links.
 For slide presentations, only the plain text lines will be displayed on the
 first slide.
@@ -22,8 +22,9 @@ import (
 )
 
 var (
-	parsers = make(map[string]ParseFunc)
-	funcs   = template.FuncMap{}
+	parsers  = make(map[string]ParseFunc)
+	funcs    = template.FuncMap{}
+	metadata = make(map[string]bool)
 )
 
 // Template returns an empty template with the action functions in its FuncMap.
@@ -64,6 +65,16 @@ func Register(name string, parser ParseFunc) {
 	parsers["."+name] = parser
 }
 
+// RegisterMetadata declares a header key, matched case-insensitively, whose
+// "key: value" lines are collected into Doc.Metadata instead of being parsed
+// as the subtitle.
+func RegisterMetadata(key string) {
+	if len(key) == 0 || strings.ContainsAny(key, ": ") {
+		panic("bad key in RegisterMetadata: " + key)
+	}
+	metadata[strings.ToLower(key)] = true
+}
+
 // Doc represents an entire document.
 type Doc struct {
 	Title      string
@@ -76,6 +87,7 @@ type Doc struct {
 	TitleNotes []string
 	Sections   []Section
 	Tags       []string
+	Metadata   map[string]string // Registered header keys, lower-cased.
 }
 
 // Author represents the person who wrote and/or is presenting the document.
@@ -489,6 +501,11 @@ func parseHeader(doc *Doc, lines *Lines) error {
 				categoryText = category[10:]
 			}
 			doc.Category = categoryText
+		} else if key, value, ok := parseMetadata(text); ok {
+			if doc.Metadata == nil {
+				doc.Metadata = make(map[string]string)
+			}
+			doc.Metadata[key] = value
 		} else if t, ok := parseTime(text); ok {
 			doc.Time = t
 		} else if doc.Subtitle == "" {
@@ -500,6 +517,20 @@ func parseHeader(doc *Doc, lines *Lines) error {
 	return nil
 }
 
+// parseMetadata splits a "key: value" header line whose key was registered
+// with RegisterMetadata.
+func parseMetadata(text string) (key, value string, ok bool) {
+	i := strings.Index(text, ":")
+	if i < 0 {
+		return "", "", false
+	}
+	key = strings.ToLower(strings.TrimSpace(text[:i]))
+	if !metadata[key] {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(text[i+1:]), true
+}
+
 func parseAuthors(lines *Lines) (authors []Author, err error) {
 	// This grammar demarcates authors with blanks.
 
@@ -0,0 +1,39 @@
+// Copyright 2012 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package present
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMetadata(t *testing.T) {
+	RegisterMetadata("rights")
+
+	const in = `Title
+Subtitle: with a colon
+Rights: CC BY 4.0
+Tags: a, b
+
+Author
+
+* Section
+
+Text.
+`
+	doc, err := Parse(strings.NewReader(in), "test.article", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := doc.Subtitle, "Subtitle: with a colon"; got != want {
+		t.Errorf("Subtitle = %q, want %q", got, want)
+	}
+	if got, want := doc.Metadata["rights"], "CC BY 4.0"; got != want {
+		t.Errorf(`Metadata["rights"] = %q, want %q`, got, want)
+	}
+	if got := len(doc.Tags); got != 2 {
+		t.Errorf("len(Tags) = %d, want 2", got)
+	}
+}